@@ -16,6 +16,7 @@ require (
 	golang.org/x/sys v0.31.0
 	gopkg.in/fsnotify.v1 v1.4.7
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -34,5 +35,4 @@ require (
 	github.com/shirou/gopsutil v2.21.11+incompatible // indirect
 	github.com/yusufpapurcu/wmi v1.2.2 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
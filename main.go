@@ -7,11 +7,10 @@ import (
 	"path/filepath"
 	"time"
 
-	"gopkg.in/yaml.v2"
-
 	"github.com/bio-routing/matroschka-prober/pkg/config"
 	"github.com/bio-routing/matroschka-prober/pkg/frontend"
 	"github.com/bio-routing/matroschka-prober/pkg/probermanager"
+	"github.com/bio-routing/matroschka-prober/pkg/target"
 	log "github.com/sirupsen/logrus"
 	inotify "gopkg.in/fsnotify.v1"
 
@@ -101,7 +100,9 @@ func main() {
 
 		err = pm.Configure(cfg)
 		if err != nil {
-			log.Fatalf("reconfiguration failed: %v", err)
+			log.Errorf("reconfiguration failed, keeping the running config: %v", err)
+			reloadFailed.SetFailed()
+			continue
 		}
 
 		reloadFailed.SetOK()
@@ -114,21 +115,16 @@ func loadConfig(path string) (*config.Config, error) {
 		return nil, fmt.Errorf("unable to read file %q: %v", path, err)
 	}
 
-	cfg := &config.Config{}
-	err = yaml.Unmarshal(cfgFile, cfg)
-	if err != nil {
-		return nil, fmt.Errorf("unable to unmarshal: %v", err)
-	}
-
-	err = cfg.ApplyDefaults()
+	cfg, err := config.Load(cfgFile, checkTargets)
 	if err != nil {
-		return nil, fmt.Errorf("error applying the defaults: %w", err)
-	}
-
-	err = cfg.ConvertIPAddresses()
-	if err != nil {
-		return nil, fmt.Errorf("error converting IP addresses: %w", err)
+		return nil, fmt.Errorf("invalid config:\n%w", err)
 	}
 
 	return cfg, nil
 }
+
+// checkTargets checks that the targets of a path can be built
+func checkTargets(p config.Path, cfg *config.Config) error {
+	_, err := target.Targets(p, cfg)
+	return err
+}
@@ -8,6 +8,43 @@ import (
 	"slices"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// MTUSweepModeBinary runs a binary search for the path MTU
+	MTUSweepModeBinary = "binary"
+	// MTUSweepModeStepped cycles through all packet sizes
+	MTUSweepModeStepped = "stepped"
+
+	// EncapsulationGRE carries the inner packet in GRE
+	EncapsulationGRE = "gre"
+	// EncapsulationMPLSOverGRE carries an MPLS label stack in GRE
+	EncapsulationMPLSOverGRE = "mpls-over-gre"
+	// EncapsulationMPLSInIP carries an MPLS label stack directly in IP (RFC 4023)
+	EncapsulationMPLSInIP = "mpls-in-ip"
+	// EncapsulationIPIP carries the inner IP packet directly in IP (protocol 4 or 41)
+	EncapsulationIPIP = "ipip"
+	// EncapsulationGREInUDP carries GRE in UDP (RFC 8086)
+	EncapsulationGREInUDP = "gre-in-udp"
+	// EncapsulationMPLSInUDP carries an MPLS label stack in UDP (RFC 7510)
+	EncapsulationMPLSInUDP = "mpls-in-udp"
+	// EncapsulationVXLAN carries an Ethernet frame in VXLAN (RFC 7348)
+	EncapsulationVXLAN = "vxlan"
+	// EncapsulationGeneve carries an Ethernet frame in Geneve (RFC 8926)
+	EncapsulationGeneve = "geneve"
+	// EncapsulationSRv6 steers the packet along the whole path with a single IPv6 header carrying a segment routing header (RFC 8754)
+	EncapsulationSRv6 = "srv6"
+
+	// CodecG711 is the G.711 codec with packet loss concealment and 20 ms packets
+	CodecG711 = "g711"
+	// CodecG729 is the G.729A codec with 20 ms packets
+	CodecG729 = "g729"
+
+	// MaxVNI is the largest VNI that fits into the 24 bits of a VXLAN or Geneve header
+	MaxVNI = 1<<24 - 1
+
+	maxMPLSLabel = 1<<20 - 1
 )
 
 var (
@@ -24,6 +61,27 @@ var (
 	dfltSrcRange            = "169.254.0.0/16"
 	dflIPv6SrcRange         = "fc00::/112"
 	dfltMetricsPath         = "/metrics"
+	dfltMTUSweepMaxBytes    = uint64(9216)
+	dfltMTUSweepStepBytes   = uint64(64)
+	dfltMPLSTTL             = uint8(64)
+	dfltOverlaySrcMAC       = "02:00:00:00:00:01"
+	dfltQuantiles           = []float64{0.5, 0.9, 0.99, 0.999}
+	dfltQuantileRelErr      = 0.01
+	dfltHistogramBuckets    = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+	classicIMIX             = []PacketSize{
+		{
+			Size:   64,
+			Weight: 7,
+		},
+		{
+			Size:   576,
+			Weight: 4,
+		},
+		{
+			Size:   1500,
+			Weight: 1,
+		},
+	}
 )
 
 // Config represents the configuration of matroschka-prober
@@ -63,9 +121,11 @@ type Config struct {
 	// description: |
 	//   List of routers used as explicit hops in the path.
 	Routers []Router `yaml:"routers,omitempty"`
-	// decription: |
-	//	 Socket receive buffer size in bytes.
+	// description: |
+	//   Socket receive buffer size in bytes.
 	Rmem int `yaml:"rmem,omitempty"`
+	// docgen:nodoc
+	lines lineIndex
 }
 
 // Defaults represents the default section of the config
@@ -74,9 +134,11 @@ type Defaults struct {
 	//   Measurement interval expressed in milliseconds.
 	//   IMPORTANT: If you are scraping the exposed metrics from /metrics, your scraping tool needs to scrape at least once in your defined interval.
 	//   E.G if you define a measurement length of 1000ms, your scraping tool muss scrape at least 1/s, otherwise the data will be gone.
+	//   Use windows_ms to aggregate the measurements over longer windows for slower scrapers.
 	MeasurementLengthMS *uint64 `yaml:"measurement_length_ms,omitempty"`
 	// description: |
-	//   Optional size of the payload (default = 0).
+	//   Optional size of the UDP payload of the probes in bytes (default = 0).
+	//   Probes are padded with zeros up to this size. Sizes smaller than the probe itself (24 bytes) have no effect.
 	PayloadSizeBytes *uint64 `yaml:"payload_size_bytes,omitempty"`
 	// description: |
 	//   Amount of probing packets that will be sent per second.
@@ -96,6 +158,19 @@ type Defaults struct {
 	// description: |
 	//  Source Interface
 	SrcInterface *string `yaml:"src_interface,omitempty"`
+	// description: |
+	//   Quantiles of the RTT exported by the matroschka_rtt summary (default = 0.5, 0.9, 0.99, 0.999).
+	Quantiles []float64 `yaml:"quantiles,omitempty"`
+	// description: |
+	//   Relative error of the exported quantiles (default = 0.01). Quantiles are estimated with sketches of constant size per window.
+	QuantileRelativeError *float64 `yaml:"quantile_relative_error,omitempty"`
+	// description: |
+	//   Upper bounds in seconds of the buckets of the matroschka_rtt_seconds histogram (default = 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1).
+	HistogramBuckets []float64 `yaml:"histogram_buckets,omitempty"`
+	// description: |
+	//   Additional windows in milliseconds the measurements are aggregated over, e.g. 60000 and 300000 for dashboards next to a measurement length of 1000 for alerting.
+	//   Each window must be a multiple of the measurement length. Metrics of these windows carry a window label, metrics of the measurement length do not.
+	WindowsMS []uint64 `yaml:"windows_ms,omitempty"`
 }
 
 // Class reperesnets a traffic class in the config file
@@ -106,6 +181,10 @@ type Class struct {
 	// description: |
 	//    Type of Service assigned to the class.
 	TOS uint8 `yaml:"tos,omitempty"`
+	// description: |
+	//   Optional voice codec of the class. If set, the E-model R-factor and MOS (ITU-T G.107) are exported for the class.
+	//   Possible values: g711, g729.
+	Codec string `yaml:"codec,omitempty"`
 }
 
 // Path represents a path to be probed
@@ -120,7 +199,7 @@ type Path struct {
 	//   Measurement interval expressed in milliseconds.
 	MeasurementLengthMS *uint64 `yaml:"measurement_length_ms,omitempty"`
 	// description: |
-	//   Payload size expressed in Bytes.
+	//   Payload size expressed in Bytes. Probes are padded with zeros up to this size.
 	PayloadSizeBytes *uint64 `yaml:"payload_size_bytes,omitempty"`
 	// description: |
 	//   Amount of probing packets that will be sent per second.
@@ -132,8 +211,76 @@ type Path struct {
 	//   custom labels to expose
 	Labels map[string]string `yaml:"labels,omitempty"`
 	// description: |
+	//   Quantiles of the RTT exported by the matroschka_rtt summary. Defaults to the quantiles of the defaults section.
+	Quantiles []float64 `yaml:"quantiles,omitempty"`
+	// description: |
+	//   Relative error of the exported quantiles. Defaults to the relative error of the defaults section.
+	QuantileRelativeError *float64 `yaml:"quantile_relative_error,omitempty"`
+	// description: |
+	//   Upper bounds in seconds of the buckets of the matroschka_rtt_seconds histogram. Defaults to the buckets of the defaults section.
+	HistogramBuckets []float64 `yaml:"histogram_buckets,omitempty"`
+	// description: |
+	//   Additional windows in milliseconds the measurements are aggregated over. Defaults to the windows of the defaults section.
+	WindowsMS []uint64 `yaml:"windows_ms,omitempty"`
+	// description: |
+	//   Distribution of payload sizes to cycle through. Each size is probed as its own target and reported with a size label.
+	//   Sizes are UDP payload sizes like payload_size_bytes. If set, payload_size_bytes is ignored.
+	SizeDistribution []PacketSize `yaml:"size_distribution,omitempty"`
+	// description: |
+	//   Use the classic simple IMIX (7x 64, 4x 576 and 1x 1500 bytes) as size distribution. Ignored if size_distribution is set.
+	//   Unlike the sizes of size_distribution, the IMIX sizes are sizes of whole packets including all headers of the probes.
+	//   Sizes smaller than the headers of the probes are sent as the smallest possible probe.
+	IMIX bool `yaml:"imix,omitempty"`
+	// docgen:nodoc
+	// SizesIncludeHeaders tells that the sizes of the size distribution are sizes of whole packets
+	SizesIncludeHeaders bool `yaml:"-"`
+	// description: |
+	//   Sweep the packet size of the path to find the largest packet that makes it back (path MTU).
+	//   Sets the DF bit on all IPv4 headers. Can not be combined with size_distribution or imix.
+	MTUSweep *MTUSweep `yaml:"mtu_sweep,omitempty"`
+	// description: |
 	//   Address family of packet returning to prober. 4 for IPv4, 6 for IPv6. If not set, the prober will use the AFI of the first hop.
+	//   If it differs from the AFI of the first hop, the src_interface must have an address of this family.
 	ReturnAFI uint8 `yaml:"return_afi,omitempty"`
+	// description: |
+	//   Range of source addresses of the packet returning to the prober. Only needed if return_afi differs from the address family of the last hop's src_range.
+	//   Defaults to 169.254.0.0/16 for IPv4 and fc00::/112 for IPv6.
+	ReturnSrcRangeStr string `yaml:"return_src_range,omitempty"`
+	// docgen:nodoc
+	ReturnSrcRange *net.IPNet `yaml:"-"`
+	// description: |
+	//   Encapsulation of the whole path, replacing the encapsulation of the routers: srv6 or any encapsulation of a router.
+	//   The encapsulation of a router is applied to every hop. Its parameters, e.g. mpls or overlay, are taken from the routers.
+	//   srv6 sends a single IPv6 header carrying a segment routing header (RFC 8754) that lists one address of the dst_range of every hop as segment,
+	//   followed by the address of the prober. All routers and the returning packet must be IPv6.
+	//   The prober host must accept segment routed packets (net.ipv6.conf.<interface>.seg6_enabled = 1).
+	Encapsulation string `yaml:"encapsulation,omitempty"`
+}
+
+// PacketSize represents a bucket of a packet size distribution
+type PacketSize struct {
+	// description: |
+	//   Payload size expressed in Bytes.
+	Size uint64 `yaml:"size"`
+	// description: |
+	//   Relative share of the probes sent with this size.
+	Weight uint64 `yaml:"weight"`
+}
+
+// MTUSweep represents the configuration of a path MTU sweep
+type MTUSweep struct {
+	// description: |
+	//   Sweep mode: binary (default) runs a binary search for the path MTU, stepped cycles through all sizes from min_bytes to max_bytes in steps of step_bytes.
+	Mode string `yaml:"mode,omitempty"`
+	// description: |
+	//   Smallest packet size to probe in bytes, including all headers. Defaults to the smallest possible probe.
+	MinBytes uint64 `yaml:"min_bytes,omitempty"`
+	// description: |
+	//   Largest packet size to probe in bytes, including all headers.
+	MaxBytes *uint64 `yaml:"max_bytes,omitempty"`
+	// description: |
+	//   Step size in bytes of the stepped mode.
+	StepBytes *uint64 `yaml:"step_bytes,omitempty"`
 }
 
 // Router represents a router used a an explicit hop in a path
@@ -148,17 +295,82 @@ type Router struct {
 	// docgen:nodoc
 	DstRange *net.IPNet `yaml:"-"`
 	// description: |
-	//   Range of source ip addresses.
+	//   Range of source ip addresses used for the headers following this router. Must belong to the same address family as dst_range.
+	//   If the next hop belongs to another address family, the src_range of the next hop is used instead.
 	// Note: for IPv6 addresses, the maximum allowed range is /112
 	SrcRangeStr string `yaml:"src_range,omitempty"`
 	// docgen:nodoc
 	SrcRange *net.IPNet `yaml:"-"`
+	// docgen:nodoc
+	// SrcRangeInherited tells that src_range is taken from the defaults
+	SrcRangeInherited bool `yaml:"-"`
+	// description: |
+	//   Encapsulation of the packets sent towards the router: gre (default), ipip, mpls-over-gre, mpls-in-ip, gre-in-udp, mpls-in-udp, vxlan or geneve.
+	//   ipip carries the next header directly as protocol 4 (IPv4) or 41 (IPv6) without GRE.
+	//   mpls-over-gre, mpls-in-ip and mpls-in-udp push the label stack configured in mpls. The router forwards the packet along the label switched path.
+	//   gre-in-udp (RFC 8086) and mpls-in-udp (RFC 7510) rotate the UDP source port with every probe to spread the probes over ECMP paths.
+	//   vxlan (RFC 7348) and geneve (RFC 8926) wrap the next header into an Ethernet frame sent to the VTEP with the VNI and MACs configured in overlay.
+	Encapsulation string `yaml:"encapsulation,omitempty"`
+	// description: |
+	//   MPLS label stack pushed by the mpls-over-gre, mpls-in-ip and mpls-in-udp encapsulations.
+	MPLS *MPLS `yaml:"mpls,omitempty"`
+	// description: |
+	//   Options of the GRE header of the gre, mpls-over-gre and gre-in-udp encapsulations.
+	GRE *GRE `yaml:"gre,omitempty"`
+	// description: |
+	//   Overlay network of the vxlan and geneve encapsulations.
+	Overlay *Overlay `yaml:"overlay,omitempty"`
 }
 
+// Overlay represents the virtual network and inner Ethernet header of a VXLAN or Geneve encapsulation
+type Overlay struct {
+	// description: |
+	//   Virtual network identifier.
+	VNI uint32 `yaml:"vni"`
+	// description: |
+	//   Source MAC address of the inner Ethernet header (default = 02:00:00:00:00:01).
+	SrcMACStr string `yaml:"src_mac,omitempty"`
+	// docgen:nodoc
+	SrcMAC net.HardwareAddr `yaml:"-"`
+	// description: |
+	//   Destination MAC address of the inner Ethernet header, e.g. the router MAC of the VTEP.
+	DstMACStr string `yaml:"dst_mac"`
+	// docgen:nodoc
+	DstMAC net.HardwareAddr `yaml:"-"`
+}
+
+// GRE represents the optional fields of a GRE header (RFC 2890)
+type GRE struct {
+	// description: |
+	//   Key of the GRE header. Useful to tell monitoring traffic apart from other GRE traffic in decapsulation filters.
+	Key *uint32 `yaml:"key,omitempty"`
+	// description: |
+	//   Add a checksum to the GRE header.
+	Checksum bool `yaml:"checksum,omitempty"`
+	// description: |
+	//   Add a sequence number to the GRE header. It carries the lower 32 bits of the probe's sequence number.
+	Sequence bool `yaml:"sequence,omitempty"`
+}
+
+// MPLS represents an MPLS label stack
+type MPLS struct {
+	// description: |
+	//   Labels from top to bottom of the stack. The traffic class of the labels is derived from the TOS of the class.
+	Labels []uint32 `yaml:"labels,omitempty"`
+	// description: |
+	//   TTL of the labels (default = 64).
+	TTL *uint8 `yaml:"ttl,omitempty"`
+}
+
+// docgen: nodoc
 type Hop struct {
-	Name     string
-	DstRange []net.IP
-	SrcRange []net.IP
+	Name          string
+	DstRange      []net.IP
+	SrcRange      []net.IP
+	Encapsulation string
+	MPLS          *MPLS
+	GRE           *GRE
+	Overlay       *Overlay
 }
 
 func (h *Hop) GetAddr(s uint64) net.IP {
@@ -167,46 +379,411 @@ func (h *Hop) GetAddr(s uint64) net.IP {
 
 func HopListsEqual(a, b []Hop) bool {
 	return slices.EqualFunc(a, b, func(a, b Hop) bool {
-		return a.Name == b.Name && IPListsEqual(a.SrcRange, b.SrcRange) && IPListsEqual(a.DstRange, b.DstRange)
+		return a.Name == b.Name && IPListsEqual(a.SrcRange, b.SrcRange) && IPListsEqual(a.DstRange, b.DstRange) &&
+			a.Encapsulation == b.Encapsulation && mplsEqual(a.MPLS, b.MPLS) && greEqual(a.GRE, b.GRE) &&
+			overlayEqual(a.Overlay, b.Overlay)
 	})
 }
 
+func overlayEqual(a, b *Overlay) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.VNI == b.VNI && slices.Equal(a.SrcMAC, b.SrcMAC) && slices.Equal(a.DstMAC, b.DstMAC)
+}
+
+func greEqual(a, b *GRE) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if (a.Key == nil) != (b.Key == nil) || (a.Key != nil && *a.Key != *b.Key) {
+		return false
+	}
+
+	return a.Checksum == b.Checksum && a.Sequence == b.Sequence
+}
+
+func mplsEqual(a, b *MPLS) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return slices.Equal(a.Labels, b.Labels) && *a.TTL == *b.TTL
+}
+
 func IPListsEqual(a, b []net.IP) bool {
 	return slices.EqualFunc(a, b, func(a, b net.IP) bool {
 		return a.Equal(b)
 	})
 }
 
-// Validate validates a configuration
-func (c *Config) Validate() error {
-	err := c.validatePaths()
+// PathCheck checks a path beyond the validation of the config, e.g. by building its targets.
+// Several errors may be joined with errors.Join.
+type PathCheck func(p Path, c *Config) error
+
+// SettingError is an error of a PathCheck caused by a single setting of a path. It is reported at the setting,
+// or once at the setting of the defaults if the path inherits it.
+// docgen: nodoc
+type SettingError struct {
+	Path string
+	// Setting is the key of the setting, e.g. quantiles
+	Setting string
+	Err     error
+}
+
+func (e *SettingError) Error() string {
+	return fmt.Sprintf("invalid %s of path %q: %v", e.Setting, e.Path, e.Err)
+}
+
+func (e *SettingError) Unwrap() error {
+	return e.Err
+}
+
+// Load parses a config, applies the defaults, converts the addresses and validates it.
+// All errors are reported together, each with its line in src.
+func Load(src []byte, checks ...PathCheck) (*Config, error) {
+	c := &Config{}
+	err := yaml.Unmarshal(src, c)
 	if err != nil {
-		return fmt.Errorf("Path validation failed: %v", err)
+		return nil, fmt.Errorf("unable to unmarshal: %v", err)
+	}
+
+	err = c.IndexLines(src)
+	if err != nil {
+		return nil, fmt.Errorf("unable to index lines: %w", err)
+	}
+
+	err = c.ApplyDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("error applying the defaults: %w", err)
+	}
+
+	v := &validationErrors{
+		lines: c.lines,
+	}
+	c.convertIPAddresses(v)
+	c.validate(v, checks)
+
+	err = v.err()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Validate validates a configuration. All errors are reported together, along with their lines if IndexLines was called.
+// The checks run on every path whose routers and own settings are valid.
+func (c *Config) Validate(checks ...PathCheck) error {
+	v := &validationErrors{
+		lines: c.lines,
+	}
+	c.validate(v, checks)
+
+	return v.err()
+}
+
+func (c *Config) validate(v *validationErrors, checks []PathCheck) {
+	n := len(v.errs)
+	c.validateDefaults(v)
+	if !c.inheritsSrcRange() {
+		validateRange(v, "src_range", "src_range", c.SrcRange)
+	}
+	c.validateClasses(v)
+	globalOK := len(v.errs) == n && c.SrcRange != nil
+
+	invalidRouters := c.validateRouters(v)
+	c.validatePaths(v, checks, globalOK, invalidRouters)
+}
+
+// validateDefaults validates the defaults once, the paths and routers inheriting them do not report them again
+func (c *Config) validateDefaults(v *validationErrors) {
+	d := c.Defaults
+	if d == nil {
+		return
+	}
+
+	validateRange(v, "defaults.src_range", "defaults.src_range", d.SrcRange)
+
+	if d.PPS != nil && *d.PPS == 0 {
+		v.addf("defaults.pps", "defaults.pps must be greater than 0")
+	}
+
+	if d.TimeoutMS != nil && d.MeasurementLengthMS != nil && *d.TimeoutMS >= *d.MeasurementLengthMS {
+		v.addf("defaults.timeout", "defaults.timeout %dms must be smaller than defaults.measurement_length_ms %dms", *d.TimeoutMS, *d.MeasurementLengthMS)
+	}
+}
+
+// inheritsSrcRange tells whether the global src_range is taken from the defaults
+func (c *Config) inheritsSrcRange() bool {
+	return c.Defaults != nil && c.SrcRangeStr != nil && c.SrcRangeStr == c.Defaults.SrcRangeStr
+}
+
+// inherits tells whether the path takes setting from the defaults
+func (p *Path) inherits(setting string, d *Defaults) bool {
+	if d == nil {
+		return false
+	}
+
+	switch setting {
+	case "measurement_length_ms":
+		return p.MeasurementLengthMS != nil && p.MeasurementLengthMS == d.MeasurementLengthMS
+	case "payload_size_bytes":
+		return p.PayloadSizeBytes != nil && p.PayloadSizeBytes == d.PayloadSizeBytes
+	case "pps":
+		return p.PPS != nil && p.PPS == d.PPS
+	case "timeout":
+		return p.TimeoutMS != nil && p.TimeoutMS == d.TimeoutMS
+	case "quantiles":
+		return sameSlice(p.Quantiles, d.Quantiles)
+	case "quantile_relative_error":
+		return p.QuantileRelativeError != nil && p.QuantileRelativeError == d.QuantileRelativeError
+	case "histogram_buckets":
+		return sameSlice(p.HistogramBuckets, d.HistogramBuckets)
+	case "windows_ms":
+		return sameSlice(p.WindowsMS, d.WindowsMS)
+	}
+
+	return false
+}
+
+// sameSlice tells whether a and b share their elements, i.e. one was assigned from the other
+func sameSlice[T any](a, b []T) bool {
+	return len(a) > 0 && len(b) > 0 && &a[0] == &b[0]
+}
+
+func (c *Config) validateClasses(v *validationErrors) {
+	names := make(map[string]struct{})
+	toss := make(map[uint8]string)
+	for i, cl := range c.Classes {
+		key := fmt.Sprintf("classes[%d]", i)
+		if _, exists := names[cl.Name]; exists {
+			v.addf(key+".name", "duplicate class name %q", cl.Name)
+		}
+		names[cl.Name] = struct{}{}
+
+		if other, exists := toss[cl.TOS]; exists {
+			v.addf(key+".tos", "TOS %#x of class %q is already used by class %q", cl.TOS, cl.Name, other)
+		} else {
+			toss[cl.TOS] = cl.Name
+		}
+
+		switch cl.Codec {
+		case "", CodecG711, CodecG729:
+		default:
+			v.addf(key+".codec", "unknown codec %q of class %q", cl.Codec, cl.Name)
+		}
+	}
+}
+
+// validateRouters returns the names of the routers that are invalid or whose ranges could not be parsed
+func (c *Config) validateRouters(v *validationErrors) map[string]struct{} {
+	invalid := make(map[string]struct{})
+	names := make(map[string]struct{})
+	for i, r := range c.Routers {
+		n := len(v.errs)
+		key := fmt.Sprintf("routers[%d]", i)
+		if _, exists := names[r.Name]; exists {
+			v.addf(key+".name", "duplicate router name %q", r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		err := r.validateEncapsulation()
+		if err != nil {
+			v.addf(key+".encapsulation", "invalid encapsulation of router %q: %v", r.Name, err)
+		}
+
+		validateRange(v, key+".dst_range", fmt.Sprintf("dst_range of router %q", r.Name), r.DstRange)
+		if !r.SrcRangeInherited {
+			validateRange(v, key+".src_range", fmt.Sprintf("src_range of router %q", r.Name), r.SrcRange)
+		}
+
+		if r.SrcRange == nil || r.DstRange == nil {
+			invalid[r.Name] = struct{}{}
+			continue
+		}
+
+		if GetIPVersion(r.SrcRange) != GetIPVersion(r.DstRange) {
+			v.addf(key+".src_range", "src_range %s and dst_range %s of router %q belong to different address families", r.SrcRange, r.DstRange, r.Name)
+		}
+
+		if len(v.errs) > n {
+			invalid[r.Name] = struct{}{}
+		}
+	}
+
+	return invalid
+}
+
+// validateRange checks that all addresses of an address range can be generated
+func validateRange(v *validationErrors, key string, name string, r *net.IPNet) {
+	if r == nil {
+		return
+	}
+
+	_, err := calculateSubnetSize(r)
+	if err != nil {
+		v.addf(key, "%s %s: %v", name, r, err)
+	}
+}
+
+func (r *Router) validateEncapsulation() error {
+	switch r.Encapsulation {
+	case "", EncapsulationGRE, EncapsulationIPIP, EncapsulationGREInUDP:
+		return nil
+	case EncapsulationMPLSOverGRE, EncapsulationMPLSInIP, EncapsulationMPLSInUDP:
+		return r.MPLS.validate()
+	case EncapsulationVXLAN, EncapsulationGeneve:
+		return r.Overlay.validate()
+	}
+
+	return fmt.Errorf("unknown encapsulation %q", r.Encapsulation)
+}
+
+func (o *Overlay) validate() error {
+	if o == nil {
+		return fmt.Errorf("overlay is missing")
+	}
+
+	err := ValidateVNI(o.VNI)
+	if err != nil {
+		return err
+	}
+
+	if len(o.DstMAC) != 6 {
+		return fmt.Errorf("dst_mac %q is not a MAC-48 address", o.DstMACStr)
+	}
+
+	if len(o.SrcMAC) != 6 {
+		return fmt.Errorf("src_mac %q is not a MAC-48 address", o.SrcMACStr)
 	}
 
 	return nil
 }
 
-func (c *Config) validatePaths() error {
-	for i := range c.Paths {
-		for j := range c.Paths[i].Hops {
-			if !c.routerExists(c.Paths[i].Hops[j]) {
-				return fmt.Errorf("Router %q of path %q does not exist", c.Paths[i].Hops[j], c.Paths[i].Name)
-			}
+// ValidateVNI checks that vni fits into a VXLAN or Geneve header
+func ValidateVNI(vni uint32) error {
+	if vni > MaxVNI {
+		return fmt.Errorf("VNI %d exceeds the maximum of %d", vni, MaxVNI)
+	}
+
+	return nil
+}
+
+func (m *MPLS) validate() error {
+	if m == nil || len(m.Labels) == 0 {
+		return fmt.Errorf("MPLS label stack is empty")
+	}
+
+	for _, l := range m.Labels {
+		if l > maxMPLSLabel {
+			return fmt.Errorf("MPLS label %d exceeds the maximum of %d", l, maxMPLSLabel)
 		}
 	}
 
 	return nil
 }
 
-func (c *Config) routerExists(needle string) bool {
-	for i := range c.Routers {
-		if c.Routers[i].Name == needle {
-			return true
+// validatePaths validates the paths. The checks only run on paths without errors that use valid routers,
+// so they neither report errors twice nor expand invalid address ranges.
+func (c *Config) validatePaths(v *validationErrors, checks []PathCheck, globalOK bool, invalidRouters map[string]struct{}) {
+	names := make(map[string]struct{})
+	reportedDefaults := make(map[string]struct{})
+	for i, p := range c.Paths {
+		n := len(v.errs)
+		runChecks := globalOK
+		key := fmt.Sprintf("paths[%d]", i)
+		if _, exists := names[p.Name]; exists {
+			v.addf(key+".name", "duplicate path name %q", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		if p.PPS != nil && *p.PPS == 0 && !p.inherits("pps", c.Defaults) {
+			v.addf(key+".pps", "pps of path %q must be greater than 0", p.Name)
+		}
+
+		if p.TimeoutMS != nil && p.MeasurementLengthMS != nil && *p.TimeoutMS >= *p.MeasurementLengthMS {
+			switch {
+			case !p.inherits("timeout", c.Defaults):
+				v.addf(key+".timeout", "timeout %dms of path %q must be smaller than the measurement length %dms", *p.TimeoutMS, p.Name, *p.MeasurementLengthMS)
+			case !p.inherits("measurement_length_ms", c.Defaults):
+				v.addf(key+".measurement_length_ms", "measurement length %dms of path %q must be greater than the timeout %dms", *p.MeasurementLengthMS, p.Name, *p.TimeoutMS)
+			}
+		}
+
+		switch p.ReturnAFI {
+		case 0, 4, 6:
+		default:
+			v.addf(key+".return_afi", "return_afi %d of path %q must be 4 or 6", p.ReturnAFI, p.Name)
+		}
+
+		validateRange(v, key+".return_src_range", fmt.Sprintf("return_src_range of path %q", p.Name), p.ReturnSrcRange)
+
+		for j, hop := range p.Hops {
+			hopKey := fmt.Sprintf("%s.hops[%d]", key, j)
+			r := getRouter(c.Routers, hop)
+			if r == nil {
+				v.addf(hopKey, "Router %q of path %q does not exist", hop, p.Name)
+				continue
+			}
+
+			if _, ok := invalidRouters[hop]; ok {
+				runChecks = false
+			}
+
+			if p.Encapsulation == "" || p.Encapsulation == EncapsulationSRv6 {
+				continue
+			}
+
+			// The routers must provide everything the encapsulation of the path needs
+			override := *r
+			override.Encapsulation = p.Encapsulation
+			err := override.validateEncapsulation()
+			if err != nil {
+				v.addf(hopKey, "invalid encapsulation of path %q at router %q: %v", p.Name, r.Name, err)
+			}
+		}
+
+		// A return_src_range that could not be parsed is reported by the conversion
+		if !runChecks || len(v.errs) > n || (p.ReturnSrcRangeStr != "" && p.ReturnSrcRange == nil) {
+			continue
+		}
+
+		for _, check := range checks {
+			err := check(p, c)
+			if err == nil {
+				continue
+			}
+
+			for _, err := range splitErrors(err) {
+				c.addCheckError(v, key, p, err, reportedDefaults)
+			}
 		}
 	}
+}
 
-	return false
+// addCheckError reports an error of a check of the path at key. Errors of settings the path inherits are reported once at the defaults.
+func (c *Config) addCheckError(v *validationErrors, key string, p Path, err error, reportedDefaults map[string]struct{}) {
+	var se *SettingError
+	if !errors.As(err, &se) {
+		v.addf(key, "%v", err)
+		return
+	}
+
+	if !p.inherits(se.Setting, c.Defaults) {
+		v.addf(key+"."+se.Setting, "%v", err)
+		return
+	}
+
+	defaultsKey := "defaults." + se.Setting
+	if _, ok := reportedDefaults[defaultsKey]; ok {
+		return
+	}
+
+	reportedDefaults[defaultsKey] = struct{}{}
+	v.addf(defaultsKey, "invalid %s: %v", defaultsKey, se.Err)
 }
 
 // ApplyDefaults applies default settings if they are missing from loaded config.
@@ -256,6 +833,19 @@ func (c *Config) ApplyDefaults() error {
 func (r *Router) applyDefaults(d *Defaults) {
 	if r.SrcRangeStr == "" {
 		r.SrcRangeStr = *d.SrcRangeStr
+		r.SrcRangeInherited = true
+	}
+
+	if r.Encapsulation == "" {
+		r.Encapsulation = EncapsulationGRE
+	}
+
+	if r.MPLS != nil && r.MPLS.TTL == nil {
+		r.MPLS.TTL = &dfltMPLSTTL
+	}
+
+	if r.Overlay != nil && r.Overlay.SrcMACStr == "" {
+		r.Overlay.SrcMACStr = dfltOverlaySrcMAC
 	}
 }
 
@@ -275,6 +865,45 @@ func (p *Path) applyDefaults(d *Defaults) {
 	if p.TimeoutMS == nil {
 		p.TimeoutMS = d.TimeoutMS
 	}
+
+	if p.Quantiles == nil {
+		p.Quantiles = d.Quantiles
+	}
+
+	if p.QuantileRelativeError == nil {
+		p.QuantileRelativeError = d.QuantileRelativeError
+	}
+
+	if p.HistogramBuckets == nil {
+		p.HistogramBuckets = d.HistogramBuckets
+	}
+
+	if p.WindowsMS == nil {
+		p.WindowsMS = d.WindowsMS
+	}
+
+	if p.IMIX && p.SizeDistribution == nil {
+		p.SizeDistribution = slices.Clone(classicIMIX)
+		p.SizesIncludeHeaders = true
+	}
+
+	if p.MTUSweep != nil {
+		p.MTUSweep.applyDefaults()
+	}
+}
+
+func (s *MTUSweep) applyDefaults() {
+	if s.Mode == "" {
+		s.Mode = MTUSweepModeBinary
+	}
+
+	if s.MaxBytes == nil {
+		s.MaxBytes = &dfltMTUSweepMaxBytes
+	}
+
+	if s.StepBytes == nil {
+		s.StepBytes = &dfltMTUSweepStepBytes
+	}
 }
 
 func (d *Defaults) applyDefaults() error {
@@ -303,6 +932,18 @@ func (d *Defaults) applyDefaults() error {
 		d.TimeoutMS = &dfltTimeoutMS
 	}
 
+	if d.Quantiles == nil {
+		d.Quantiles = slices.Clone(dfltQuantiles)
+	}
+
+	if d.QuantileRelativeError == nil {
+		d.QuantileRelativeError = &dfltQuantileRelErr
+	}
+
+	if d.HistogramBuckets == nil {
+		d.HistogramBuckets = slices.Clone(dfltHistogramBuckets)
+	}
+
 	return nil
 }
 
@@ -375,9 +1016,13 @@ func (c *Config) PathToProberHops(pathCfg Path) ([]Hop, error) {
 		}
 
 		h := Hop{
-			Name:     r.Name,
-			DstRange: GenerateAddrs(r.DstRange),
-			SrcRange: GenerateAddrs(r.SrcRange),
+			Name:          r.Name,
+			DstRange:      GenerateAddrs(r.DstRange),
+			SrcRange:      GenerateAddrs(r.SrcRange),
+			Encapsulation: r.Encapsulation,
+			MPLS:          r.MPLS,
+			GRE:           r.GRE,
+			Overlay:       r.Overlay,
 		}
 		res = append(res, h)
 
@@ -413,15 +1058,12 @@ func calculateSubnetSize(subnet *net.IPNet) (uint32, error) {
 		return 0, fmt.Errorf("invalid subnet mask")
 	}
 
-	// Calculate the number of IP addresses in the subnet
-	numIPs := uint32(1) << uint(bits-ones)
-
-	// Check if the number of IPs exceeds 2^16
-	if numIPs > (1 << 16) {
+	// Check if the number of IPs exceeds 2^16 before shifting, larger subnets would overflow
+	if bits-ones > 16 {
 		return 0, fmt.Errorf("number of IP addresses exceeds 2^16")
 	}
 
-	return numIPs, nil
+	return uint32(1) << uint(bits-ones), nil
 }
 
 // incrementIP increments an IP address by one
@@ -467,40 +1109,81 @@ func generateIPList(network *net.IPNet) ([]net.IP, error) {
 	return ipList, nil
 }
 
+// ConvertIPAddresses parses the addresses, ranges and MACs of the config. All errors are reported together.
 func (c *Config) ConvertIPAddresses() error {
+	v := &validationErrors{
+		lines: c.lines,
+	}
+	c.convertIPAddresses(v)
+
+	return v.err()
+}
+
+func (c *Config) convertIPAddresses(v *validationErrors) {
 	var err error
 	if c.ListenAddressStr != nil {
 		c.ListenAddress, err = stringToAddrPort(*c.ListenAddressStr)
 		if err != nil {
-			return fmt.Errorf("there was an error parsing listen_addres: %w", err)
+			v.addf("listen_address", "there was an error parsing listen_address: %v", err)
 		}
 	}
 
-	if c.SrcRangeStr != nil {
+	c.Defaults.SrcRange, err = convertIPRange(*c.Defaults.SrcRangeStr)
+	if err != nil {
+		v.addf("defaults.src_range", "there was an error parsing defaults.src_range: %v", err)
+	}
+
+	// Ranges inherited from the defaults are reported at the defaults only
+	if c.inheritsSrcRange() {
+		c.SrcRange = c.Defaults.SrcRange
+	} else if c.SrcRangeStr != nil {
 		c.SrcRange, err = convertIPRange(*c.SrcRangeStr)
 		if err != nil {
-			return fmt.Errorf("there was an erro parsing src_range: %w", err)
+			v.addf("src_range", "there was an error parsing src_range: %v", err)
 		}
 	}
 
-	c.Defaults.SrcRange, err = convertIPRange(*c.Defaults.SrcRangeStr)
-	if err != nil {
-		return fmt.Errorf("there was an error parsing defaults.src_range: %w", err)
+	for key, path := range c.Paths {
+		if path.ReturnSrcRangeStr == "" {
+			continue
+		}
+
+		c.Paths[key].ReturnSrcRange, err = convertIPRange(path.ReturnSrcRangeStr)
+		if err != nil {
+			v.addf(fmt.Sprintf("paths[%d].return_src_range", key), "there was an error parsing paths.return_src_range: %v", err)
+		}
 	}
 
 	for key, router := range c.Routers {
+		routerKey := fmt.Sprintf("routers[%d]", key)
 		c.Routers[key].DstRange, err = convertIPRange(router.DstRangeStr)
 		if err != nil {
-			return fmt.Errorf("there was an error parsing routers.dst_range: %w", err)
+			v.addf(routerKey+".dst_range", "there was an error parsing routers.dst_range: %v", err)
+		}
+
+		if router.SrcRangeInherited {
+			c.Routers[key].SrcRange = c.Defaults.SrcRange
+		} else {
+			c.Routers[key].SrcRange, err = convertIPRange(router.SrcRangeStr)
+			if err != nil {
+				v.addf(routerKey+".src_range", "there was an error parsing router.src_range: %v", err)
+			}
 		}
 
-		c.Routers[key].SrcRange, err = convertIPRange(router.SrcRangeStr)
+		if router.Overlay == nil {
+			continue
+		}
+
+		router.Overlay.SrcMAC, err = net.ParseMAC(router.Overlay.SrcMACStr)
 		if err != nil {
-			return fmt.Errorf("there was an error parsing router.src_range: %w", err)
+			v.addf(routerKey+".overlay.src_mac", "there was an error parsing router.overlay.src_mac: %v", err)
 		}
-	}
 
-	return nil
+		router.Overlay.DstMAC, err = net.ParseMAC(router.Overlay.DstMACStr)
+		if err != nil {
+			v.addf(routerKey+".overlay.dst_mac", "there was an error parsing router.overlay.dst_mac: %v", err)
+		}
+	}
 }
 
 func convertIPAddress(s string) (net.IP, error) {
@@ -540,6 +1223,16 @@ func convertIPRange(s string) (*net.IPNet, error) {
 	return ipRange, nil
 }
 
+// DefaultSrcRange returns the default source range of an address family
+func DefaultSrcRange(afi uint8) *net.IPNet {
+	r := initDefaultRange(dfltSrcRange)
+	if afi == 6 {
+		r = initDefaultRange(dflIPv6SrcRange)
+	}
+
+	return &r
+}
+
 func initDefaultRange(ip string) net.IPNet {
 	_, ipRange, err := net.ParseCIDR(ip)
 	if err != nil {
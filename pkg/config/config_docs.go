@@ -9,18 +9,23 @@ import (
 )
 
 var (
-	ConfigDoc   encoder.Doc
-	DefaultsDoc encoder.Doc
-	ClassDoc    encoder.Doc
-	PathDoc     encoder.Doc
-	RouterDoc   encoder.Doc
+	ConfigDoc     encoder.Doc
+	DefaultsDoc   encoder.Doc
+	ClassDoc      encoder.Doc
+	PathDoc       encoder.Doc
+	PacketSizeDoc encoder.Doc
+	MTUSweepDoc   encoder.Doc
+	RouterDoc     encoder.Doc
+	OverlayDoc    encoder.Doc
+	GREDoc        encoder.Doc
+	MPLSDoc       encoder.Doc
 )
 
 func init() {
 	ConfigDoc.Type = "Config"
 	ConfigDoc.Comments[encoder.LineComment] = "Config represents the configuration of matroschka-prober"
 	ConfigDoc.Description = "Config represents the configuration of matroschka-prober"
-	ConfigDoc.Fields = make([]encoder.Doc, 8)
+	ConfigDoc.Fields = make([]encoder.Doc, 9)
 	ConfigDoc.Fields[0].Name = "metrcis_path"
 	ConfigDoc.Fields[0].Type = "string"
 	ConfigDoc.Fields[0].Note = ""
@@ -61,6 +66,11 @@ func init() {
 	ConfigDoc.Fields[7].Note = ""
 	ConfigDoc.Fields[7].Description = "List of routers used as explicit hops in the path."
 	ConfigDoc.Fields[7].Comments[encoder.LineComment] = "List of routers used as explicit hops in the path."
+	ConfigDoc.Fields[8].Name = "rmem"
+	ConfigDoc.Fields[8].Type = "int"
+	ConfigDoc.Fields[8].Note = ""
+	ConfigDoc.Fields[8].Description = "Socket receive buffer size in bytes."
+	ConfigDoc.Fields[8].Comments[encoder.LineComment] = "Socket receive buffer size in bytes."
 
 	DefaultsDoc.Type = "Defaults"
 	DefaultsDoc.Comments[encoder.LineComment] = "Defaults represents the default section of the config"
@@ -71,17 +81,17 @@ func init() {
 			FieldName: "defaults",
 		},
 	}
-	DefaultsDoc.Fields = make([]encoder.Doc, 6)
+	DefaultsDoc.Fields = make([]encoder.Doc, 10)
 	DefaultsDoc.Fields[0].Name = "measurement_length_ms"
 	DefaultsDoc.Fields[0].Type = "uint64"
 	DefaultsDoc.Fields[0].Note = ""
-	DefaultsDoc.Fields[0].Description = "Measurement interval expressed in milliseconds.\nIMPORTANT: If you are scraping the exposed metrics from /metrics, your scraping tool needs to scrape at least once in your defined interval.\nE.G if you define a measurement length of 1000ms, your scraping tool muss scrape at least 1/s, otherwise the data will be gone."
+	DefaultsDoc.Fields[0].Description = "Measurement interval expressed in milliseconds.\nIMPORTANT: If you are scraping the exposed metrics from /metrics, your scraping tool needs to scrape at least once in your defined interval.\nE.G if you define a measurement length of 1000ms, your scraping tool muss scrape at least 1/s, otherwise the data will be gone.\nUse windows_ms to aggregate the measurements over longer windows for slower scrapers."
 	DefaultsDoc.Fields[0].Comments[encoder.LineComment] = "Measurement interval expressed in milliseconds."
 	DefaultsDoc.Fields[1].Name = "payload_size_bytes"
 	DefaultsDoc.Fields[1].Type = "uint64"
 	DefaultsDoc.Fields[1].Note = ""
-	DefaultsDoc.Fields[1].Description = "Optional size of the payload (default = 0)."
-	DefaultsDoc.Fields[1].Comments[encoder.LineComment] = "Optional size of the payload (default = 0)."
+	DefaultsDoc.Fields[1].Description = "Optional size of the UDP payload of the probes in bytes (default = 0).\nProbes are padded with zeros up to this size. Sizes smaller than the probe itself (24 bytes) have no effect."
+	DefaultsDoc.Fields[1].Comments[encoder.LineComment] = "Optional size of the UDP payload of the probes in bytes (default = 0)."
 	DefaultsDoc.Fields[2].Name = "pps"
 	DefaultsDoc.Fields[2].Type = "uint64"
 	DefaultsDoc.Fields[2].Note = ""
@@ -102,6 +112,26 @@ func init() {
 	DefaultsDoc.Fields[5].Note = ""
 	DefaultsDoc.Fields[5].Description = "Source Interface"
 	DefaultsDoc.Fields[5].Comments[encoder.LineComment] = "Source Interface"
+	DefaultsDoc.Fields[6].Name = "quantiles"
+	DefaultsDoc.Fields[6].Type = "[]float64"
+	DefaultsDoc.Fields[6].Note = ""
+	DefaultsDoc.Fields[6].Description = "Quantiles of the RTT exported by the matroschka_rtt summary (default = 0.5, 0.9, 0.99, 0.999)."
+	DefaultsDoc.Fields[6].Comments[encoder.LineComment] = "Quantiles of the RTT exported by the matroschka_rtt summary (default = 0.5, 0.9, 0.99, 0.999)."
+	DefaultsDoc.Fields[7].Name = "quantile_relative_error"
+	DefaultsDoc.Fields[7].Type = "float64"
+	DefaultsDoc.Fields[7].Note = ""
+	DefaultsDoc.Fields[7].Description = "Relative error of the exported quantiles (default = 0.01). Quantiles are estimated with sketches of constant size per window."
+	DefaultsDoc.Fields[7].Comments[encoder.LineComment] = "Relative error of the exported quantiles (default = 0.01). Quantiles are estimated with sketches of constant size per window."
+	DefaultsDoc.Fields[8].Name = "histogram_buckets"
+	DefaultsDoc.Fields[8].Type = "[]float64"
+	DefaultsDoc.Fields[8].Note = ""
+	DefaultsDoc.Fields[8].Description = "Upper bounds in seconds of the buckets of the matroschka_rtt_seconds histogram (default = 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1)."
+	DefaultsDoc.Fields[8].Comments[encoder.LineComment] = "Upper bounds in seconds of the buckets of the matroschka_rtt_seconds histogram (default = 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1)."
+	DefaultsDoc.Fields[9].Name = "windows_ms"
+	DefaultsDoc.Fields[9].Type = "[]uint64"
+	DefaultsDoc.Fields[9].Note = ""
+	DefaultsDoc.Fields[9].Description = "Additional windows in milliseconds the measurements are aggregated over, e.g. 60000 and 300000 for dashboards next to a measurement length of 1000 for alerting.\nEach window must be a multiple of the measurement length. Metrics of these windows carry a window label, metrics of the measurement length do not."
+	DefaultsDoc.Fields[9].Comments[encoder.LineComment] = "Additional windows in milliseconds the measurements are aggregated over, e.g. 60000 and 300000 for dashboards next to a measurement length of 1000 for alerting."
 
 	ClassDoc.Type = "Class"
 	ClassDoc.Comments[encoder.LineComment] = "Class reperesnets a traffic class in the config file"
@@ -112,7 +142,7 @@ func init() {
 			FieldName: "classes",
 		},
 	}
-	ClassDoc.Fields = make([]encoder.Doc, 2)
+	ClassDoc.Fields = make([]encoder.Doc, 3)
 	ClassDoc.Fields[0].Name = "name"
 	ClassDoc.Fields[0].Type = "string"
 	ClassDoc.Fields[0].Note = ""
@@ -123,6 +153,11 @@ func init() {
 	ClassDoc.Fields[1].Note = ""
 	ClassDoc.Fields[1].Description = "Type of Service assigned to the class."
 	ClassDoc.Fields[1].Comments[encoder.LineComment] = "Type of Service assigned to the class."
+	ClassDoc.Fields[2].Name = "codec"
+	ClassDoc.Fields[2].Type = "string"
+	ClassDoc.Fields[2].Note = ""
+	ClassDoc.Fields[2].Description = "Optional voice codec of the class. If set, the E-model R-factor and MOS (ITU-T G.107) are exported for the class.\nPossible values: g711, g729."
+	ClassDoc.Fields[2].Comments[encoder.LineComment] = "Optional voice codec of the class. If set, the E-model R-factor and MOS (ITU-T G.107) are exported for the class."
 
 	PathDoc.Type = "Path"
 	PathDoc.Comments[encoder.LineComment] = "Path represents a path to be probed"
@@ -133,7 +168,7 @@ func init() {
 			FieldName: "paths",
 		},
 	}
-	PathDoc.Fields = make([]encoder.Doc, 7)
+	PathDoc.Fields = make([]encoder.Doc, 17)
 	PathDoc.Fields[0].Name = "name"
 	PathDoc.Fields[0].Type = "string"
 	PathDoc.Fields[0].Note = ""
@@ -152,8 +187,8 @@ func init() {
 	PathDoc.Fields[3].Name = "payload_size_bytes"
 	PathDoc.Fields[3].Type = "uint64"
 	PathDoc.Fields[3].Note = ""
-	PathDoc.Fields[3].Description = "Payload size expressed in Bytes."
-	PathDoc.Fields[3].Comments[encoder.LineComment] = "Payload size expressed in Bytes."
+	PathDoc.Fields[3].Description = "Payload size expressed in Bytes. Probes are padded with zeros up to this size."
+	PathDoc.Fields[3].Comments[encoder.LineComment] = "Payload size expressed in Bytes. Probes are padded with zeros up to this size."
 	PathDoc.Fields[4].Name = "pps"
 	PathDoc.Fields[4].Type = "uint64"
 	PathDoc.Fields[4].Note = ""
@@ -169,6 +204,108 @@ func init() {
 	PathDoc.Fields[6].Note = ""
 	PathDoc.Fields[6].Description = "custom labels to expose"
 	PathDoc.Fields[6].Comments[encoder.LineComment] = "custom labels to expose"
+	PathDoc.Fields[7].Name = "quantiles"
+	PathDoc.Fields[7].Type = "[]float64"
+	PathDoc.Fields[7].Note = ""
+	PathDoc.Fields[7].Description = "Quantiles of the RTT exported by the matroschka_rtt summary. Defaults to the quantiles of the defaults section."
+	PathDoc.Fields[7].Comments[encoder.LineComment] = "Quantiles of the RTT exported by the matroschka_rtt summary. Defaults to the quantiles of the defaults section."
+	PathDoc.Fields[8].Name = "quantile_relative_error"
+	PathDoc.Fields[8].Type = "float64"
+	PathDoc.Fields[8].Note = ""
+	PathDoc.Fields[8].Description = "Relative error of the exported quantiles. Defaults to the relative error of the defaults section."
+	PathDoc.Fields[8].Comments[encoder.LineComment] = "Relative error of the exported quantiles. Defaults to the relative error of the defaults section."
+	PathDoc.Fields[9].Name = "histogram_buckets"
+	PathDoc.Fields[9].Type = "[]float64"
+	PathDoc.Fields[9].Note = ""
+	PathDoc.Fields[9].Description = "Upper bounds in seconds of the buckets of the matroschka_rtt_seconds histogram. Defaults to the buckets of the defaults section."
+	PathDoc.Fields[9].Comments[encoder.LineComment] = "Upper bounds in seconds of the buckets of the matroschka_rtt_seconds histogram. Defaults to the buckets of the defaults section."
+	PathDoc.Fields[10].Name = "windows_ms"
+	PathDoc.Fields[10].Type = "[]uint64"
+	PathDoc.Fields[10].Note = ""
+	PathDoc.Fields[10].Description = "Additional windows in milliseconds the measurements are aggregated over. Defaults to the windows of the defaults section."
+	PathDoc.Fields[10].Comments[encoder.LineComment] = "Additional windows in milliseconds the measurements are aggregated over. Defaults to the windows of the defaults section."
+	PathDoc.Fields[11].Name = "size_distribution"
+	PathDoc.Fields[11].Type = "[]PacketSize"
+	PathDoc.Fields[11].Note = ""
+	PathDoc.Fields[11].Description = "Distribution of payload sizes to cycle through. Each size is probed as its own target and reported with a size label.\nSizes are UDP payload sizes like payload_size_bytes. If set, payload_size_bytes is ignored."
+	PathDoc.Fields[11].Comments[encoder.LineComment] = "Distribution of payload sizes to cycle through. Each size is probed as its own target and reported with a size label."
+	PathDoc.Fields[12].Name = "imix"
+	PathDoc.Fields[12].Type = "bool"
+	PathDoc.Fields[12].Note = ""
+	PathDoc.Fields[12].Description = "Use the classic simple IMIX (7x 64, 4x 576 and 1x 1500 bytes) as size distribution. Ignored if size_distribution is set.\nUnlike the sizes of size_distribution, the IMIX sizes are sizes of whole packets including all headers of the probes.\nSizes smaller than the headers of the probes are sent as the smallest possible probe."
+	PathDoc.Fields[12].Comments[encoder.LineComment] = "Use the classic simple IMIX (7x 64, 4x 576 and 1x 1500 bytes) as size distribution. Ignored if size_distribution is set."
+	PathDoc.Fields[13].Name = "mtu_sweep"
+	PathDoc.Fields[13].Type = "MTUSweep"
+	PathDoc.Fields[13].Note = ""
+	PathDoc.Fields[13].Description = "Sweep the packet size of the path to find the largest packet that makes it back (path MTU).\nSets the DF bit on all IPv4 headers. Can not be combined with size_distribution or imix."
+	PathDoc.Fields[13].Comments[encoder.LineComment] = "Sweep the packet size of the path to find the largest packet that makes it back (path MTU)."
+	PathDoc.Fields[14].Name = "return_afi"
+	PathDoc.Fields[14].Type = "uint8"
+	PathDoc.Fields[14].Note = ""
+	PathDoc.Fields[14].Description = "Address family of packet returning to prober. 4 for IPv4, 6 for IPv6. If not set, the prober will use the AFI of the first hop.\nIf it differs from the AFI of the first hop, the src_interface must have an address of this family."
+	PathDoc.Fields[14].Comments[encoder.LineComment] = "Address family of packet returning to prober. 4 for IPv4, 6 for IPv6. If not set, the prober will use the AFI of the first hop."
+	PathDoc.Fields[15].Name = "return_src_range"
+	PathDoc.Fields[15].Type = "string"
+	PathDoc.Fields[15].Note = ""
+	PathDoc.Fields[15].Description = "Range of source addresses of the packet returning to the prober. Only needed if return_afi differs from the address family of the last hop's src_range.\nDefaults to 169.254.0.0/16 for IPv4 and fc00::/112 for IPv6."
+	PathDoc.Fields[15].Comments[encoder.LineComment] = "Range of source addresses of the packet returning to the prober. Only needed if return_afi differs from the address family of the last hop's src_range."
+	PathDoc.Fields[16].Name = "encapsulation"
+	PathDoc.Fields[16].Type = "string"
+	PathDoc.Fields[16].Note = ""
+	PathDoc.Fields[16].Description = "Encapsulation of the whole path, replacing the encapsulation of the routers: srv6 or any encapsulation of a router.\nThe encapsulation of a router is applied to every hop. Its parameters, e.g. mpls or overlay, are taken from the routers.\nsrv6 sends a single IPv6 header carrying a segment routing header (RFC 8754) that lists one address of the dst_range of every hop as segment,\nfollowed by the address of the prober. All routers and the returning packet must be IPv6.\nThe prober host must accept segment routed packets (net.ipv6.conf.<interface>.seg6_enabled = 1)."
+	PathDoc.Fields[16].Comments[encoder.LineComment] = "Encapsulation of the whole path, replacing the encapsulation of the routers: srv6 or any encapsulation of a router."
+
+	PacketSizeDoc.Type = "PacketSize"
+	PacketSizeDoc.Comments[encoder.LineComment] = "PacketSize represents a bucket of a packet size distribution"
+	PacketSizeDoc.Description = "PacketSize represents a bucket of a packet size distribution"
+	PacketSizeDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Path",
+			FieldName: "size_distribution",
+		},
+	}
+	PacketSizeDoc.Fields = make([]encoder.Doc, 2)
+	PacketSizeDoc.Fields[0].Name = "size"
+	PacketSizeDoc.Fields[0].Type = "uint64"
+	PacketSizeDoc.Fields[0].Note = ""
+	PacketSizeDoc.Fields[0].Description = "Payload size expressed in Bytes."
+	PacketSizeDoc.Fields[0].Comments[encoder.LineComment] = "Payload size expressed in Bytes."
+	PacketSizeDoc.Fields[1].Name = "weight"
+	PacketSizeDoc.Fields[1].Type = "uint64"
+	PacketSizeDoc.Fields[1].Note = ""
+	PacketSizeDoc.Fields[1].Description = "Relative share of the probes sent with this size."
+	PacketSizeDoc.Fields[1].Comments[encoder.LineComment] = "Relative share of the probes sent with this size."
+
+	MTUSweepDoc.Type = "MTUSweep"
+	MTUSweepDoc.Comments[encoder.LineComment] = "MTUSweep represents the configuration of a path MTU sweep"
+	MTUSweepDoc.Description = "MTUSweep represents the configuration of a path MTU sweep"
+	MTUSweepDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Path",
+			FieldName: "mtu_sweep",
+		},
+	}
+	MTUSweepDoc.Fields = make([]encoder.Doc, 4)
+	MTUSweepDoc.Fields[0].Name = "mode"
+	MTUSweepDoc.Fields[0].Type = "string"
+	MTUSweepDoc.Fields[0].Note = ""
+	MTUSweepDoc.Fields[0].Description = "Sweep mode: binary (default) runs a binary search for the path MTU, stepped cycles through all sizes from min_bytes to max_bytes in steps of step_bytes."
+	MTUSweepDoc.Fields[0].Comments[encoder.LineComment] = "Sweep mode: binary (default) runs a binary search for the path MTU, stepped cycles through all sizes from min_bytes to max_bytes in steps of step_bytes."
+	MTUSweepDoc.Fields[1].Name = "min_bytes"
+	MTUSweepDoc.Fields[1].Type = "uint64"
+	MTUSweepDoc.Fields[1].Note = ""
+	MTUSweepDoc.Fields[1].Description = "Smallest packet size to probe in bytes, including all headers. Defaults to the smallest possible probe."
+	MTUSweepDoc.Fields[1].Comments[encoder.LineComment] = "Smallest packet size to probe in bytes, including all headers. Defaults to the smallest possible probe."
+	MTUSweepDoc.Fields[2].Name = "max_bytes"
+	MTUSweepDoc.Fields[2].Type = "uint64"
+	MTUSweepDoc.Fields[2].Note = ""
+	MTUSweepDoc.Fields[2].Description = "Largest packet size to probe in bytes, including all headers."
+	MTUSweepDoc.Fields[2].Comments[encoder.LineComment] = "Largest packet size to probe in bytes, including all headers."
+	MTUSweepDoc.Fields[3].Name = "step_bytes"
+	MTUSweepDoc.Fields[3].Type = "uint64"
+	MTUSweepDoc.Fields[3].Note = ""
+	MTUSweepDoc.Fields[3].Description = "Step size in bytes of the stepped mode."
+	MTUSweepDoc.Fields[3].Comments[encoder.LineComment] = "Step size in bytes of the stepped mode."
 
 	RouterDoc.Type = "Router"
 	RouterDoc.Comments[encoder.LineComment] = "Router represents a router used a an explicit hop in a path"
@@ -179,7 +316,7 @@ func init() {
 			FieldName: "routers",
 		},
 	}
-	RouterDoc.Fields = make([]encoder.Doc, 3)
+	RouterDoc.Fields = make([]encoder.Doc, 7)
 	RouterDoc.Fields[0].Name = "name"
 	RouterDoc.Fields[0].Type = "string"
 	RouterDoc.Fields[0].Note = ""
@@ -193,8 +330,101 @@ func init() {
 	RouterDoc.Fields[2].Name = "src_range"
 	RouterDoc.Fields[2].Type = "string"
 	RouterDoc.Fields[2].Note = ""
-	RouterDoc.Fields[2].Description = "Range of source ip addresses."
-	RouterDoc.Fields[2].Comments[encoder.LineComment] = "Range of source ip addresses."
+	RouterDoc.Fields[2].Description = "Range of source ip addresses used for the headers following this router. Must belong to the same address family as dst_range.\nIf the next hop belongs to another address family, the src_range of the next hop is used instead."
+	RouterDoc.Fields[2].Comments[encoder.LineComment] = "Range of source ip addresses used for the headers following this router. Must belong to the same address family as dst_range."
+	RouterDoc.Fields[3].Name = "encapsulation"
+	RouterDoc.Fields[3].Type = "string"
+	RouterDoc.Fields[3].Note = ""
+	RouterDoc.Fields[3].Description = "Encapsulation of the packets sent towards the router: gre (default), ipip, mpls-over-gre, mpls-in-ip, gre-in-udp, mpls-in-udp, vxlan or geneve.\nipip carries the next header directly as protocol 4 (IPv4) or 41 (IPv6) without GRE.\nmpls-over-gre, mpls-in-ip and mpls-in-udp push the label stack configured in mpls. The router forwards the packet along the label switched path.\ngre-in-udp (RFC 8086) and mpls-in-udp (RFC 7510) rotate the UDP source port with every probe to spread the probes over ECMP paths.\nvxlan (RFC 7348) and geneve (RFC 8926) wrap the next header into an Ethernet frame sent to the VTEP with the VNI and MACs configured in overlay."
+	RouterDoc.Fields[3].Comments[encoder.LineComment] = "Encapsulation of the packets sent towards the router: gre (default), ipip, mpls-over-gre, mpls-in-ip, gre-in-udp, mpls-in-udp, vxlan or geneve."
+	RouterDoc.Fields[4].Name = "mpls"
+	RouterDoc.Fields[4].Type = "MPLS"
+	RouterDoc.Fields[4].Note = ""
+	RouterDoc.Fields[4].Description = "MPLS label stack pushed by the mpls-over-gre, mpls-in-ip and mpls-in-udp encapsulations."
+	RouterDoc.Fields[4].Comments[encoder.LineComment] = "MPLS label stack pushed by the mpls-over-gre, mpls-in-ip and mpls-in-udp encapsulations."
+	RouterDoc.Fields[5].Name = "gre"
+	RouterDoc.Fields[5].Type = "GRE"
+	RouterDoc.Fields[5].Note = ""
+	RouterDoc.Fields[5].Description = "Options of the GRE header of the gre, mpls-over-gre and gre-in-udp encapsulations."
+	RouterDoc.Fields[5].Comments[encoder.LineComment] = "Options of the GRE header of the gre, mpls-over-gre and gre-in-udp encapsulations."
+	RouterDoc.Fields[6].Name = "overlay"
+	RouterDoc.Fields[6].Type = "Overlay"
+	RouterDoc.Fields[6].Note = ""
+	RouterDoc.Fields[6].Description = "Overlay network of the vxlan and geneve encapsulations."
+	RouterDoc.Fields[6].Comments[encoder.LineComment] = "Overlay network of the vxlan and geneve encapsulations."
+
+	OverlayDoc.Type = "Overlay"
+	OverlayDoc.Comments[encoder.LineComment] = "Overlay represents the virtual network and inner Ethernet header of a VXLAN or Geneve encapsulation"
+	OverlayDoc.Description = "Overlay represents the virtual network and inner Ethernet header of a VXLAN or Geneve encapsulation"
+	OverlayDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Router",
+			FieldName: "overlay",
+		},
+	}
+	OverlayDoc.Fields = make([]encoder.Doc, 3)
+	OverlayDoc.Fields[0].Name = "vni"
+	OverlayDoc.Fields[0].Type = "uint32"
+	OverlayDoc.Fields[0].Note = ""
+	OverlayDoc.Fields[0].Description = "Virtual network identifier."
+	OverlayDoc.Fields[0].Comments[encoder.LineComment] = "Virtual network identifier."
+	OverlayDoc.Fields[1].Name = "src_mac"
+	OverlayDoc.Fields[1].Type = "string"
+	OverlayDoc.Fields[1].Note = ""
+	OverlayDoc.Fields[1].Description = "Source MAC address of the inner Ethernet header (default = 02:00:00:00:00:01)."
+	OverlayDoc.Fields[1].Comments[encoder.LineComment] = "Source MAC address of the inner Ethernet header (default = 02:00:00:00:00:01)."
+	OverlayDoc.Fields[2].Name = "dst_mac"
+	OverlayDoc.Fields[2].Type = "string"
+	OverlayDoc.Fields[2].Note = ""
+	OverlayDoc.Fields[2].Description = "Destination MAC address of the inner Ethernet header, e.g. the router MAC of the VTEP."
+	OverlayDoc.Fields[2].Comments[encoder.LineComment] = "Destination MAC address of the inner Ethernet header, e.g. the router MAC of the VTEP."
+
+	GREDoc.Type = "GRE"
+	GREDoc.Comments[encoder.LineComment] = "GRE represents the optional fields of a GRE header (RFC 2890)"
+	GREDoc.Description = "GRE represents the optional fields of a GRE header (RFC 2890)"
+	GREDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Router",
+			FieldName: "gre",
+		},
+	}
+	GREDoc.Fields = make([]encoder.Doc, 3)
+	GREDoc.Fields[0].Name = "key"
+	GREDoc.Fields[0].Type = "uint32"
+	GREDoc.Fields[0].Note = ""
+	GREDoc.Fields[0].Description = "Key of the GRE header. Useful to tell monitoring traffic apart from other GRE traffic in decapsulation filters."
+	GREDoc.Fields[0].Comments[encoder.LineComment] = "Key of the GRE header. Useful to tell monitoring traffic apart from other GRE traffic in decapsulation filters."
+	GREDoc.Fields[1].Name = "checksum"
+	GREDoc.Fields[1].Type = "bool"
+	GREDoc.Fields[1].Note = ""
+	GREDoc.Fields[1].Description = "Add a checksum to the GRE header."
+	GREDoc.Fields[1].Comments[encoder.LineComment] = "Add a checksum to the GRE header."
+	GREDoc.Fields[2].Name = "sequence"
+	GREDoc.Fields[2].Type = "bool"
+	GREDoc.Fields[2].Note = ""
+	GREDoc.Fields[2].Description = "Add a sequence number to the GRE header. It carries the lower 32 bits of the probe's sequence number."
+	GREDoc.Fields[2].Comments[encoder.LineComment] = "Add a sequence number to the GRE header. It carries the lower 32 bits of the probe's sequence number."
+
+	MPLSDoc.Type = "MPLS"
+	MPLSDoc.Comments[encoder.LineComment] = "MPLS represents an MPLS label stack"
+	MPLSDoc.Description = "MPLS represents an MPLS label stack"
+	MPLSDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Router",
+			FieldName: "mpls",
+		},
+	}
+	MPLSDoc.Fields = make([]encoder.Doc, 2)
+	MPLSDoc.Fields[0].Name = "labels"
+	MPLSDoc.Fields[0].Type = "[]uint32"
+	MPLSDoc.Fields[0].Note = ""
+	MPLSDoc.Fields[0].Description = "Labels from top to bottom of the stack. The traffic class of the labels is derived from the TOS of the class."
+	MPLSDoc.Fields[0].Comments[encoder.LineComment] = "Labels from top to bottom of the stack. The traffic class of the labels is derived from the TOS of the class."
+	MPLSDoc.Fields[1].Name = "ttl"
+	MPLSDoc.Fields[1].Type = "uint8"
+	MPLSDoc.Fields[1].Note = ""
+	MPLSDoc.Fields[1].Description = "TTL of the labels (default = 64)."
+	MPLSDoc.Fields[1].Comments[encoder.LineComment] = "TTL of the labels (default = 64)."
 }
 
 func (_ Config) Doc() *encoder.Doc {
@@ -213,10 +443,30 @@ func (_ Path) Doc() *encoder.Doc {
 	return &PathDoc
 }
 
+func (_ PacketSize) Doc() *encoder.Doc {
+	return &PacketSizeDoc
+}
+
+func (_ MTUSweep) Doc() *encoder.Doc {
+	return &MTUSweepDoc
+}
+
 func (_ Router) Doc() *encoder.Doc {
 	return &RouterDoc
 }
 
+func (_ Overlay) Doc() *encoder.Doc {
+	return &OverlayDoc
+}
+
+func (_ GRE) Doc() *encoder.Doc {
+	return &GREDoc
+}
+
+func (_ MPLS) Doc() *encoder.Doc {
+	return &MPLSDoc
+}
+
 // GetconfigDoc returns documentation for the file pkg/config/config_docs.go.
 func GetconfigDoc() *encoder.FileDoc {
 	return &encoder.FileDoc{
@@ -227,7 +477,12 @@ func GetconfigDoc() *encoder.FileDoc {
 			&DefaultsDoc,
 			&ClassDoc,
 			&PathDoc,
+			&PacketSizeDoc,
+			&MTUSweepDoc,
 			&RouterDoc,
+			&OverlayDoc,
+			&GREDoc,
+			&MPLSDoc,
 		},
 	}
 }
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 )
 
 func TestConfigApplyDefaults(t *testing.T) {
@@ -34,7 +37,10 @@ func TestConfigApplyDefaults(t *testing.T) {
 						IP:   net.IP{169, 254, 0, 0},
 						Mask: net.IPMask{255, 255, 0, 0},
 					},
-					TimeoutMS: &dfltTimeoutMS,
+					TimeoutMS:             &dfltTimeoutMS,
+					Quantiles:             dfltQuantiles,
+					QuantileRelativeError: &dfltQuantileRelErr,
+					HistogramBuckets:      dfltHistogramBuckets,
 				},
 				Classes: []Class{
 					{
@@ -82,7 +88,10 @@ func TestConfigApplyDefaults(t *testing.T) {
 						IP:   net.IP{169, 254, 0, 0},
 						Mask: net.IPMask{255, 255, 0, 0},
 					},
-					TimeoutMS: &dfltTimeoutMS,
+					TimeoutMS:             &dfltTimeoutMS,
+					Quantiles:             dfltQuantiles,
+					QuantileRelativeError: &dfltQuantileRelErr,
+					HistogramBuckets:      dfltHistogramBuckets,
 				},
 				Paths: []Path{
 					{
@@ -90,18 +99,22 @@ func TestConfigApplyDefaults(t *testing.T) {
 						Hops: []string{
 							"SomeRouter02.SomeMetro01",
 						},
-						MeasurementLengthMS: &dfltMeasurementLengthMS,
-						PayloadSizeBytes:    &dfltPayloadSizeBytes,
-						PPS:                 &dfltPPS,
-						TimeoutMS:           &dfltTimeoutMS,
+						MeasurementLengthMS:   &dfltMeasurementLengthMS,
+						PayloadSizeBytes:      &dfltPayloadSizeBytes,
+						PPS:                   &dfltPPS,
+						TimeoutMS:             &dfltTimeoutMS,
+						Quantiles:             dfltQuantiles,
+						QuantileRelativeError: &dfltQuantileRelErr,
+						HistogramBuckets:      dfltHistogramBuckets,
 					},
 				},
 				Routers: []Router{
 					{
-						Name:        "SomeRouter02.SomeMetro01",
-						DstRange:    parseNetwork("192.168.0.0/24"),
-						SrcRange:    parseNetwork("192.168.100.0/24"),
-						SrcRangeStr: "192.168.100.0/24",
+						Name:          "SomeRouter02.SomeMetro01",
+						DstRange:      parseNetwork("192.168.0.0/24"),
+						SrcRange:      parseNetwork("192.168.100.0/24"),
+						SrcRangeStr:   "192.168.100.0/24",
+						Encapsulation: EncapsulationGRE,
 					},
 				},
 				Classes: []Class{
@@ -120,6 +133,485 @@ func TestConfigApplyDefaults(t *testing.T) {
 	}
 }
 
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{
+			name: "valid config",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name: "path01",
+						Hops: []string{"router01"},
+					},
+				},
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("192.168.0.0/24"),
+						SrcRange: parseNetwork("192.168.100.0/24"),
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "voice class",
+			cfg: &Config{
+				Classes: []Class{
+					{
+						Name:  "EF",
+						TOS:   0xb8,
+						Codec: CodecG729,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown codec",
+			cfg: &Config{
+				Classes: []Class{
+					{
+						Name:  "EF",
+						TOS:   0xb8,
+						Codec: "pigeon",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown path encapsulation",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name:          "path01",
+						Hops:          []string{"router01"},
+						Encapsulation: "srv7",
+					},
+				},
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("2001:db8::/120"),
+						SrcRange: parseNetwork("2001:db8:1::/120"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mpls-in-udp path over router without labels",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name:          "path01",
+						Hops:          []string{"router01"},
+						Encapsulation: EncapsulationMPLSInUDP,
+					},
+				},
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("192.168.0.0/24"),
+						SrcRange: parseNetwork("192.168.100.0/24"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "vxlan path over router without overlay",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name:          "path01",
+						Hops:          []string{"router01"},
+						Encapsulation: EncapsulationVXLAN,
+					},
+				},
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("192.168.0.0/24"),
+						SrcRange: parseNetwork("192.168.100.0/24"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown router",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name: "path01",
+						Hops: []string{"router02"},
+					},
+				},
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("192.168.0.0/24"),
+						SrcRange: parseNetwork("192.168.100.0/24"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "src and dst range AFI mismatch",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("2001:db8::/128"),
+						SrcRange: parseNetwork("192.168.100.0/24"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mpls encapsulation without labels",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:          "router01",
+						DstRange:      parseNetwork("192.168.0.0/24"),
+						SrcRange:      parseNetwork("192.168.100.0/24"),
+						Encapsulation: EncapsulationMPLSOverGRE,
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "vxlan encapsulation without overlay",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:          "vtep01",
+						DstRange:      parseNetwork("192.168.0.0/24"),
+						SrcRange:      parseNetwork("192.168.100.0/24"),
+						Encapsulation: EncapsulationVXLAN,
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mpls label out of range",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:          "router01",
+						DstRange:      parseNetwork("192.168.0.0/24"),
+						SrcRange:      parseNetwork("192.168.100.0/24"),
+						Encapsulation: EncapsulationMPLSInIP,
+						MPLS: &MPLS{
+							Labels: []uint32{1 << 20},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate names",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name: "path01",
+					},
+					{
+						Name: "path01",
+					},
+				},
+				Routers: []Router{
+					{
+						Name: "router01",
+					},
+					{
+						Name: "router01",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate TOS",
+			cfg: &Config{
+				Classes: []Class{
+					{
+						Name: "BE",
+						TOS:  0x00,
+					},
+					{
+						Name: "CS0",
+						TOS:  0x00,
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "src range too large",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("192.168.0.0/24"),
+						SrcRange: parseNetwork("10.0.0.0/15"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "IPv6 src range too large",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("2001:db8::/120"),
+						SrcRange: parseNetwork("2001:db8:1::/64"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "whole IPv4 address space as dst range",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("0.0.0.0/0"),
+						SrcRange: parseNetwork("10.0.0.0/24"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "largest allowed ranges",
+			cfg: &Config{
+				Routers: []Router{
+					{
+						Name:     "router01",
+						DstRange: parseNetwork("2001:db8::/112"),
+						SrcRange: parseNetwork("2001:db8:1::/112"),
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "zero pps",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name: "path01",
+						PPS:  uint64ptr(0),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "timeout not smaller than measurement length",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name:                "path01",
+						TimeoutMS:           uint64ptr(1000),
+						MeasurementLengthMS: uint64ptr(1000),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid return_afi",
+			cfg: &Config{
+				Paths: []Path{
+					{
+						Name:      "path01",
+						ReturnAFI: 5,
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.cfg.Validate()
+		if test.wantErr {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+	}
+}
+
+func TestConfigValidateLines(t *testing.T) {
+	src := []byte(`classes:
+  - name: BE
+  - name: BE
+paths:
+  - name: path01
+    hops:
+      - router01
+    pps: 0
+routers:
+  - name: router02
+    dst_range: 192.168.0.0/24
+    src_range: 2001:db8::/120
+`)
+
+	cfg := &Config{}
+	err := yaml.Unmarshal(src, cfg)
+	assert.NoError(t, err)
+
+	err = cfg.IndexLines(src)
+	assert.NoError(t, err)
+
+	err = cfg.ApplyDefaults()
+	assert.NoError(t, err)
+
+	err = cfg.ConvertIPAddresses()
+	assert.NoError(t, err)
+
+	err = cfg.Validate()
+	assert.EqualError(t, err, `line 3: duplicate class name "BE"
+line 3: TOS 0x0 of class "BE" is already used by class "BE"
+line 7: Router "router01" of path "path01" does not exist
+line 8: pps of path "path01" must be greater than 0
+line 12: src_range 2001:db8::/120 and dst_range 192.168.0.0/24 of router "router02" belong to different address families`)
+}
+
+func TestLoad(t *testing.T) {
+	src := []byte(`paths:
+  - name: path01
+    hops:
+      - router01
+  - name: path02
+    hops:
+      - router01
+  - name: path03
+    hops:
+      - router02
+routers:
+  - name: router01
+    dst_range: 192.168.0.0/24
+    src_range: 192.168.100.0/24
+  - name: router02
+    dst_range: 192.168.0.300/24
+    src_range: 192.168.100.0/24
+`)
+
+	// The check reports every path it runs on, it must skip path03 as its router is invalid
+	check := func(p Path, c *Config) error {
+		return fmt.Errorf("check of path %q failed", p.Name)
+	}
+
+	cfg, err := Load(src, check)
+	assert.Nil(t, cfg)
+	assert.EqualError(t, err, `line 2: check of path "path01" failed
+line 5: check of path "path02" failed
+line 16: there was an error parsing routers.dst_range: invalid CIDR address: 192.168.0.300/24`)
+
+	cfg, err = Load([]byte(`paths:
+  - name: path01
+    hops:
+      - router01
+routers:
+  - name: router01
+    dst_range: 192.168.0.0/24
+    src_range: 192.168.100.0/24
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "path01", cfg.Paths[0].Name)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	src := []byte(`defaults:
+  src_range: 10.0.0.0/8
+  pps: 0
+paths:
+  - name: path01
+    hops:
+      - router01
+  - name: path02
+    hops:
+      - router01
+    measurement_length_ms: 500
+routers:
+  - name: router01
+    dst_range: 192.168.0.0/24
+`)
+
+	// Inherited values are reported once at the defaults
+	cfg, err := Load(src)
+	assert.Nil(t, cfg)
+	assert.EqualError(t, err, `line 2: defaults.src_range 10.0.0.0/8: number of IP addresses exceeds 2^16
+line 3: defaults.pps must be greater than 0
+line 11: measurement length 500ms of path "path02" must be greater than the timeout 500ms`)
+
+	src = []byte(`defaults:
+  quantiles: [0.5, 1.5]
+paths:
+  - name: path01
+    hops:
+      - router01
+  - name: path02
+    hops:
+      - router01
+  - name: path03
+    hops:
+      - router01
+    quantiles: [2]
+routers:
+  - name: router01
+    dst_range: 192.168.0.0/24
+    src_range: 192.168.100.0/24
+`)
+
+	// The check reports all errors of a path, errors of inherited settings are reported once at the defaults
+	check := func(p Path, c *Config) error {
+		errs := []error{
+			fmt.Errorf("check of path %q failed", p.Name),
+		}
+
+		for _, q := range p.Quantiles {
+			if q > 1 {
+				errs = append(errs, &SettingError{
+					Path:    p.Name,
+					Setting: "quantiles",
+					Err:     fmt.Errorf("quantile %v is not within [0, 1]", q),
+				})
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	cfg, err = Load(src, check)
+	assert.Nil(t, cfg)
+	assert.EqualError(t, err, `line 2: invalid defaults.quantiles: quantile 1.5 is not within [0, 1]
+line 4: check of path "path01" failed
+line 7: check of path "path02" failed
+line 10: check of path "path03" failed
+line 13: invalid quantiles of path "path03": quantile 2 is not within [0, 1]`)
+}
+
 func TestGenerateAddrs(t *testing.T) {
 	tests := []struct {
 		addrRange   *net.IPNet
@@ -190,3 +682,7 @@ func parseNetwork(network string) *net.IPNet {
 	_, ret, _ := net.ParseCIDR(network)
 	return ret
 }
+
+func uint64ptr(v uint64) *uint64 {
+	return &v
+}
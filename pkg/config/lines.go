@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lineIndex maps the location of a config element, e.g. paths[1].pps, to its line in the YAML source
+type lineIndex map[string]int
+
+// IndexLines records the line numbers of the YAML source of the config. Validation errors refer to these lines.
+func (c *Config) IndexLines(src []byte) error {
+	var root yaml.Node
+	err := yaml.Unmarshal(src, &root)
+	if err != nil {
+		return fmt.Errorf("unable to parse: %w", err)
+	}
+
+	c.lines = make(lineIndex)
+	if len(root.Content) > 0 {
+		c.lines.add("", root.Content[0])
+	}
+
+	return nil
+}
+
+func (l lineIndex) add(key string, n *yaml.Node) {
+	l[key] = n.Line
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			if key != "" {
+				k = key + "." + k
+			}
+
+			// The key is reported rather than its value, so e.g. a nested mapping points to its own name
+			l.add(k, n.Content[i+1])
+			l[k] = n.Content[i].Line
+		}
+	case yaml.SequenceNode:
+		for i, e := range n.Content {
+			l.add(fmt.Sprintf("%s[%d]", key, i), e)
+		}
+	}
+}
+
+// line returns the line of key or of the closest enclosing element present in the source. Elements set by defaults have no line.
+func (l lineIndex) line(key string) int {
+	for key != "" {
+		if line, ok := l[key]; ok {
+			return line
+		}
+
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+
+		key = key[:i]
+	}
+
+	return 0
+}
+
+// validationErrors collects the errors of a validation run along with their lines
+type validationErrors struct {
+	lines lineIndex
+	errs  []lineError
+}
+
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e lineError) Error() string {
+	if e.line == 0 {
+		return e.err.Error()
+	}
+
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (v *validationErrors) addf(key string, format string, args ...any) {
+	v.errs = append(v.errs, lineError{
+		line: v.lines.line(key),
+		err:  fmt.Errorf(format, args...),
+	})
+}
+
+// splitErrors returns the errors joined in err
+func splitErrors(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+
+	return []error{err}
+}
+
+// err returns all collected errors ordered by line or nil if there are none
+func (v *validationErrors) err() error {
+	slices.SortStableFunc(v.errs, func(a, b lineError) int {
+		return a.line - b.line
+	})
+
+	errs := make([]error, 0, len(v.errs))
+	for _, e := range v.errs {
+		errs = append(errs, e)
+	}
+
+	return errors.Join(errs...)
+}
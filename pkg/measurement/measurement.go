@@ -1,7 +1,6 @@
 package measurement
 
 import (
-	"slices"
 	"sync"
 	"time"
 
@@ -16,7 +15,21 @@ type Measurement struct {
 	RTTSum   uint64
 	RTTMin   uint64
 	RTTMax   uint64
-	RTTs     []uint64
+	// RTTs is a sketch of the distribution of the RTTs
+	RTTs *Sketch
+	// Jitter is the interarrival jitter (RFC 3550) after the last probe that arrived
+	Jitter float64
+	// IPDVs is a sketch of the delay variations (RFC 3393) of consecutive probes
+	IPDVs   *Sketch
+	IPDVSum uint64
+	IPDVMax uint64
+}
+
+func newMeasurement(relativeError float64) *Measurement {
+	return &Measurement{
+		RTTs:  NewSketch(relativeError),
+		IPDVs: NewSketch(relativeError),
+	}
 }
 
 func (m *Measurement) copy() *Measurement {
@@ -30,63 +43,95 @@ func (m *Measurement) copy() *Measurement {
 		RTTSum:   m.RTTSum,
 		RTTMin:   m.RTTMin,
 		RTTMax:   m.RTTMax,
-		RTTs:     slices.Clone(m.RTTs),
+		RTTs:     m.RTTs.copy(),
+		Jitter:   m.Jitter,
+		IPDVs:    m.IPDVs.copy(),
+		IPDVSum:  m.IPDVSum,
+		IPDVMax:  m.IPDVMax,
+	}
+}
+
+// merge adds the probes of o to m. o must be the measurement of a later window.
+func (m *Measurement) merge(o *Measurement) {
+	m.Sent += o.Sent
+	m.Received += o.Received
+	m.RTTSum += o.RTTSum
+	m.RTTs.Merge(o.RTTs)
+	m.IPDVs.Merge(o.IPDVs)
+	m.IPDVSum += o.IPDVSum
+	m.IPDVMax = max(m.IPDVMax, o.IPDVMax)
+
+	if o.RTTMin != 0 && (o.RTTMin < m.RTTMin || m.RTTMin == 0) {
+		m.RTTMin = o.RTTMin
+	}
+
+	m.RTTMax = max(m.RTTMax, o.RTTMax)
+
+	if o.Received != 0 {
+		m.Jitter = o.Jitter
 	}
 }
 
-// MeasurementsDB manages measurements
+// Quantiles returns the estimated RTTs at the quantiles qs
+func (m *Measurement) Quantiles(qs []float64) map[float64]uint64 {
+	return m.RTTs.Quantiles(qs)
+}
+
+// MeasurementsDB manages measurements. Every target has its own ring of window buckets and lock, so probes of
+// different targets do not contend.
 type MeasurementsDB struct {
-	m map[int64]map[*target.Target]*Measurement
-	l sync.RWMutex
+	rings sync.Map // *target.Target -> *ring
 }
 
 // NewDB creates a new measurements database
 func NewDB() *MeasurementsDB {
-	return &MeasurementsDB{
-		m: make(map[int64]map[*target.Target]*Measurement),
-	}
+	return &MeasurementsDB{}
 }
 
-// AddSent adds a sent probe to the db
-func (m *MeasurementsDB) AddSent(t *target.Target, ts int64) {
-	m.l.Lock()
-
-	if m.m[ts] == nil {
-		m.m[ts] = make(map[*target.Target]*Measurement)
+// getRing returns the ring of t. It is created if create is set, otherwise nil is returned for unknown targets.
+func (m *MeasurementsDB) getRing(t *target.Target, create bool) *ring {
+	r, ok := m.rings.Load(t)
+	if ok {
+		return r.(*ring)
 	}
 
-	if m.m[ts][t] == nil {
-		m.m[ts][t] = &Measurement{}
+	if !create {
+		return nil
 	}
 
-	m.m[ts][t].Sent++
-	m.l.Unlock() // This is not defered for performance reason
+	r, _ = m.rings.LoadOrStore(t, newRing(t.Config()))
+	return r.(*ring)
 }
 
-// AddRecv adds a received probe to the db
-func (m *MeasurementsDB) AddRecv(sentTsNS int64, rtt uint64, t *target.Target) {
-	m.l.RLock()
+// AddSent adds a sent probe to the db
+func (m *MeasurementsDB) AddSent(t *target.Target, ts int64) {
+	r := m.getRing(t, true)
 
-	allignedTs := sentTsNS - sentTsNS%int64(t.Config().MeasurementLengthMS*uint64(time.Millisecond))
-	if _, ok := m.m[allignedTs]; !ok {
-		log.Debugf("Received probe at %d sent at %d with rtt %d after bucket %d was removed. Now=%d", sentTsNS+int64(rtt), sentTsNS, allignedTs, rtt, time.Now().UnixNano())
-		m.l.RUnlock() // This is not defered for performance reason
-		return
-	}
+	r.mu.Lock()
+	r.bucket(ts).Sent++
+	r.mu.Unlock() // This is not defered for performance reason
+}
 
-	if m.m[allignedTs] == nil {
-		m.l.RUnlock()
+// AddRecv adds a received probe with the per target sequence number seq to the db
+func (m *MeasurementsDB) AddRecv(sentTsNS int64, seq uint64, rtt uint64, t *target.Target) {
+	r := m.getRing(t, false)
+	if r == nil {
+		// Target was removed by a reconfiguration
 		return
 	}
 
-	if m.m[allignedTs][t] == nil {
-		m.l.RUnlock()
+	allignedTs := sentTsNS - sentTsNS%r.measurementLengthNS
+
+	r.mu.Lock()
+	me := r.get(allignedTs)
+	if me == nil {
+		r.mu.Unlock() // This is not defered for performance reason
+		log.Debugf("Received probe at %d sent at %d with rtt %d after bucket %d was removed. Now=%d", sentTsNS+int64(rtt), sentTsNS, rtt, allignedTs, time.Now().UnixNano())
 		return
 	}
 
-	me := m.m[allignedTs][t]
 	me.Received++
-	me.RTTs = append(me.RTTs, rtt)
+	me.RTTs.Add(rtt)
 	me.RTTSum += rtt
 
 	if rtt < me.RTTMin || me.RTTMin == 0 {
@@ -97,29 +142,54 @@ func (m *MeasurementsDB) AddRecv(sentTsNS int64, rtt uint64, t *target.Target) {
 		me.RTTMax = rtt
 	}
 
-	m.l.RUnlock() // This is not defered for performance reason
-}
+	var ipdvs []uint64
+	me.Jitter, ipdvs = t.DelayVariation(seq, rtt)
+	for _, ipdv := range ipdvs {
+		me.IPDVs.Add(ipdv)
+		me.IPDVSum += ipdv
+		me.IPDVMax = max(me.IPDVMax, ipdv)
+	}
 
-// RemoveOlder removes all probes from the db that are older than ts
-func (m *MeasurementsDB) RemoveOlder(ts int64) {
-	m.l.Lock()
-	defer m.l.Unlock()
+	r.mu.Unlock() // This is not defered for performance reason
 
-	for t := range m.m {
-		if t < ts {
-			delete(m.m, t)
-		}
-	}
+	t.ObserveRTT(rtt)
+}
+
+// Remove removes all measurements of t
+func (m *MeasurementsDB) Remove(t *target.Target) {
+	m.rings.Delete(t)
 }
 
 // Get get's the measurement at ts
 func (m *MeasurementsDB) Get(ts int64, t *target.Target) *Measurement {
-	m.l.RLock()
-	defer m.l.RUnlock()
+	return m.GetWindow(ts, int64(t.Config().MeasurementLengthMS*uint64(time.Millisecond)), t)
+}
 
-	if _, ok := m.m[ts]; !ok {
+// GetWindow merges the measurements of the window of length lengthNS starting at ts.
+// The window must be a multiple of the measurement length of the target.
+func (m *MeasurementsDB) GetWindow(ts int64, lengthNS int64, t *target.Target) *Measurement {
+	r := m.getRing(t, false)
+	if r == nil {
 		return nil
 	}
 
-	return m.m[ts][t].copy()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ret *Measurement
+	for bucket := ts; bucket < ts+lengthNS; bucket += r.measurementLengthNS {
+		me := r.get(bucket)
+		if me == nil {
+			continue
+		}
+
+		if ret == nil {
+			ret = me.copy()
+			continue
+		}
+
+		ret.merge(me)
+	}
+
+	return ret
 }
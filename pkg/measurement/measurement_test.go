@@ -0,0 +1,202 @@
+package measurement
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/bio-routing/matroschka-prober/pkg/target"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasurementQuantiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		rtts      []uint64
+		quantiles []float64
+		expected  map[float64]uint64
+	}{
+		{
+			name:      "no rtts",
+			rtts:      nil,
+			quantiles: []float64{0.5, 0.99},
+			expected:  map[float64]uint64{},
+		},
+		{
+			name:      "single rtt",
+			rtts:      []uint64{42000},
+			quantiles: []float64{0, 0.5, 1},
+			expected: map[float64]uint64{
+				0:   42000,
+				0.5: 42000,
+				1:   42000,
+			},
+		},
+		{
+			name:      "unsorted rtts",
+			rtts:      []uint64{100000, 10000, 90000, 20000, 80000, 30000, 70000, 40000, 60000, 50000},
+			quantiles: []float64{0.5, 0.9, 0.99, 0.999},
+			expected: map[float64]uint64{
+				0.5:   50000,
+				0.9:   90000,
+				0.99:  100000,
+				0.999: 100000,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		m := newMeasurement(0.01)
+		for _, rtt := range test.rtts {
+			m.RTTs.Add(rtt)
+		}
+
+		got := m.Quantiles(test.quantiles)
+		assert.Len(t, got, len(test.expected), test.name)
+		for q, v := range test.expected {
+			assert.InEpsilon(t, v, got[q], 0.01, test.name)
+		}
+	}
+}
+
+func TestMeasurementMerge(t *testing.T) {
+	m := newMeasurement(0.01)
+	m.Sent = 3
+	m.Received = 2
+	m.RTTSum = 30
+	m.RTTMin = 10
+	m.RTTMax = 20
+	m.RTTs.Add(10)
+	m.RTTs.Add(20)
+	m.Jitter = 1.5
+	m.IPDVs.Add(10)
+	m.IPDVSum = 10
+	m.IPDVMax = 10
+
+	lost := newMeasurement(0.01)
+	lost.Sent = 2
+	m.merge(lost)
+
+	o := newMeasurement(0.01)
+	o.Sent = 2
+	o.Received = 2
+	o.RTTSum = 35
+	o.RTTMin = 5
+	o.RTTMax = 30
+	o.RTTs.Add(30)
+	o.RTTs.Add(5)
+	o.Jitter = 2.5
+	o.IPDVs.Add(25)
+	o.IPDVSum = 25
+	o.IPDVMax = 25
+	m.merge(o)
+
+	assert.Equal(t, uint64(7), m.Sent)
+	assert.Equal(t, uint64(4), m.Received)
+	assert.Equal(t, uint64(65), m.RTTSum)
+	assert.Equal(t, uint64(5), m.RTTMin)
+	assert.Equal(t, uint64(30), m.RTTMax)
+	assert.Equal(t, uint64(4), m.RTTs.Count())
+	assert.Equal(t, 2.5, m.Jitter)
+	assert.Equal(t, uint64(2), m.IPDVs.Count())
+	assert.Equal(t, uint64(35), m.IPDVSum)
+	assert.Equal(t, uint64(25), m.IPDVMax)
+}
+
+func newTestTarget(t *testing.T, windowsMS []uint64) *target.Target {
+	ta, err := target.NewTarget(target.TargetConfig{
+		Name: "test-target",
+		Hops: []config.Hop{
+			{
+				SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+				DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+			},
+		},
+		SrcAddrs:              []net.IP{net.ParseIP("192.0.2.0")},
+		MeasurementLengthMS:   1000,
+		TimeoutMS:             500,
+		QuantileRelativeError: 0.01,
+		WindowsMS:             windowsMS,
+	}, net.ParseIP("192.0.2.0"))
+	if err != nil {
+		t.Fatalf("unable to create target: %v", err)
+	}
+
+	return ta
+}
+
+func TestMeasurementsDB(t *testing.T) {
+	second := int64(time.Second)
+	ta := newTestTarget(t, []uint64{3000})
+	db := NewDB()
+
+	assert.Nil(t, db.Get(0, ta), "unknown target")
+
+	// ring of (2*3000+500)/1000+2 = 8 buckets
+	for ts := int64(0); ts < 10; ts++ {
+		db.AddSent(ta, ts*second)
+		db.AddSent(ta, ts*second)
+		db.AddRecv(ts*second+10, uint64(ts), uint64(ts+1)*1000000, ta)
+	}
+
+	assert.Nil(t, db.Get(0, ta), "rotated out")
+	assert.Nil(t, db.Get(1*second, ta), "rotated out")
+	db.AddRecv(1*second, 1, 1000000, ta)
+	assert.Equal(t, uint64(1), db.Get(9*second, ta).Received, "late probe of a rotated bucket")
+
+	m := db.Get(2*second, ta)
+	assert.Equal(t, uint64(2), m.Sent)
+	assert.Equal(t, uint64(1), m.Received)
+	assert.Equal(t, uint64(3000000), m.RTTMin)
+
+	m = db.GetWindow(6*second, 3*second, ta)
+	assert.Equal(t, uint64(6), m.Sent)
+	assert.Equal(t, uint64(3), m.Received)
+	assert.Equal(t, uint64(7000000), m.RTTMin)
+	assert.Equal(t, uint64(9000000), m.RTTMax)
+
+	m = db.GetWindow(9*second, 3*second, ta)
+	assert.Equal(t, uint64(2), m.Sent, "window in progress")
+
+	db.Remove(ta)
+	assert.Nil(t, db.Get(9*second, ta), "removed target")
+	db.AddRecv(9*second, 9, 1000000, ta)
+	assert.Nil(t, db.Get(9*second, ta), "probe of removed target")
+}
+
+func TestMeasurementsDBConcurrent(t *testing.T) {
+	second := int64(time.Second)
+	targets := []*target.Target{newTestTarget(t, nil), newTestTarget(t, []uint64{2000})}
+	db := NewDB()
+
+	wg := sync.WaitGroup{}
+	for _, ta := range targets {
+		for range 4 {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				for i := range 1000 {
+					db.AddSent(ta, int64(i%20)*second)
+				}
+			}()
+
+			go func() {
+				defer wg.Done()
+				for i := range 1000 {
+					db.AddRecv(int64(i%20)*second, uint64(i), uint64(i)*1000, ta)
+				}
+			}()
+
+			go func() {
+				defer wg.Done()
+				for i := range 1000 {
+					db.GetWindow(int64(i%20)*second, 2*second, ta)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,63 @@
+package measurement
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bio-routing/matroschka-prober/pkg/target"
+)
+
+// ring holds the measurements of a target in one bucket per measurement length. Buckets are reused once they are
+// older than the longest window of the target can reach back, so old measurements are dropped by rotation.
+type ring struct {
+	mu                  sync.Mutex
+	measurementLengthNS int64
+	relativeError       float64
+	buckets             []bucket
+}
+
+type bucket struct {
+	ts int64
+	m  *Measurement
+}
+
+func newRing(cfg target.TargetConfig) *ring {
+	measurementLengthNS := int64(cfg.MeasurementLengthMS) * int64(time.Millisecond)
+	longestWindowNS := int64(slices.Max(cfg.Windows())) * int64(time.Millisecond)
+	timeoutNS := int64(cfg.TimeoutMS) * int64(time.Millisecond)
+
+	// The last finished window starts up to two windows and the timeout in the past. One more bucket is being filled.
+	n := (2*longestWindowNS+timeoutNS)/measurementLengthNS + 2
+
+	return &ring{
+		measurementLengthNS: measurementLengthNS,
+		relativeError:       cfg.QuantileRelativeError,
+		buckets:             make([]bucket, n),
+	}
+}
+
+func (r *ring) index(ts int64) int {
+	return int((ts / r.measurementLengthNS) % int64(len(r.buckets)))
+}
+
+// bucket returns the measurement at ts. It replaces the measurement of an older window using the same bucket.
+func (r *ring) bucket(ts int64) *Measurement {
+	b := &r.buckets[r.index(ts)]
+	if b.m == nil || b.ts != ts {
+		b.ts = ts
+		b.m = newMeasurement(r.relativeError)
+	}
+
+	return b.m
+}
+
+// get returns the measurement at ts or nil if there is none
+func (r *ring) get(ts int64) *Measurement {
+	b := r.buckets[r.index(ts)]
+	if b.m == nil || b.ts != ts {
+		return nil
+	}
+
+	return b.m
+}
@@ -0,0 +1,125 @@
+package measurement
+
+import (
+	"math"
+	"slices"
+)
+
+// Sketch is a DDSketch (Masson et al., VLDB 2019) of a distribution of non-negative integers. Quantiles are estimated
+// with a relative error of at most the relative accuracy the sketch was created with. Memory grows with the logarithm
+// of the range of the values only and sketches of the same accuracy can be merged.
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+	// zeros counts the values that can not be mapped to a bin
+	zeros uint64
+	count uint64
+	// offset is the index of the first bin
+	offset int
+	bins   []uint64
+}
+
+// NewSketch creates a sketch with the relative accuracy alpha within (0, 1)
+func NewSketch(alpha float64) *Sketch {
+	gamma := (1 + alpha) / (1 - alpha)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+	}
+}
+
+func (s *Sketch) index(v uint64) int {
+	return int(math.Ceil(math.Log(float64(v)) / s.logGamma))
+}
+
+// value returns the estimate of the values in bin i
+func (s *Sketch) value(i int) uint64 {
+	return uint64(math.Round(2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)))
+}
+
+// Add adds v to the sketch
+func (s *Sketch) Add(v uint64) {
+	s.count++
+	if v == 0 {
+		s.zeros++
+		return
+	}
+
+	s.addToBin(s.index(v), 1)
+}
+
+func (s *Sketch) addToBin(i int, n uint64) {
+	if len(s.bins) == 0 {
+		s.offset = i
+	}
+
+	if i < s.offset {
+		s.bins = append(make([]uint64, s.offset-i), s.bins...)
+		s.offset = i
+	}
+
+	if i >= s.offset+len(s.bins) {
+		s.bins = append(s.bins, make([]uint64, i-s.offset-len(s.bins)+1)...)
+	}
+
+	s.bins[i-s.offset] += n
+}
+
+// Merge adds all values of o to s. Both sketches must have the same relative accuracy.
+func (s *Sketch) Merge(o *Sketch) {
+	s.count += o.count
+	s.zeros += o.zeros
+	for i, n := range o.bins {
+		if n == 0 {
+			continue
+		}
+
+		s.addToBin(o.offset+i, n)
+	}
+}
+
+// Count returns the number of values in the sketch
+func (s *Sketch) Count() uint64 {
+	return s.count
+}
+
+// Quantiles returns the estimated values at the quantiles qs using the nearest rank method
+func (s *Sketch) Quantiles(qs []float64) map[float64]uint64 {
+	ret := make(map[float64]uint64, len(qs))
+	if s.count == 0 {
+		return ret
+	}
+
+	for _, q := range qs {
+		rank := max(uint64(math.Ceil(q*float64(s.count))), 1)
+		ret[q] = s.valueAtRank(rank)
+	}
+
+	return ret
+}
+
+func (s *Sketch) valueAtRank(rank uint64) uint64 {
+	cumulative := s.zeros
+	if rank <= cumulative {
+		return 0
+	}
+
+	for i, n := range s.bins {
+		cumulative += n
+		if rank <= cumulative {
+			return s.value(s.offset + i)
+		}
+	}
+
+	return s.value(s.offset + len(s.bins) - 1)
+}
+
+func (s *Sketch) copy() *Sketch {
+	if s == nil {
+		return nil
+	}
+
+	ret := *s
+	ret.bins = slices.Clone(s.bins)
+	return &ret
+}
@@ -0,0 +1,55 @@
+package measurement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSketchRelativeError(t *testing.T) {
+	for _, alpha := range []float64{0.05, 0.01, 0.001} {
+		s := NewSketch(alpha)
+		for v := uint64(1); v <= 100000; v++ {
+			s.Add(v * 97)
+		}
+
+		qs := []float64{0.01, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999, 1}
+		got := s.Quantiles(qs)
+		for _, q := range qs {
+			expected := uint64(q*100000) * 97
+			assert.InEpsilon(t, expected, got[q], alpha+1e-9, "alpha %v quantile %v", alpha, q)
+		}
+	}
+}
+
+func TestSketchZeros(t *testing.T) {
+	s := NewSketch(0.01)
+	s.Add(0)
+	s.Add(0)
+	s.Add(1000)
+
+	got := s.Quantiles([]float64{0.5, 1})
+	assert.Equal(t, uint64(0), got[0.5])
+	assert.InEpsilon(t, 1000, got[1], 0.01)
+}
+
+func TestSketchMerge(t *testing.T) {
+	a := NewSketch(0.01)
+	b := NewSketch(0.01)
+	all := NewSketch(0.01)
+	for v := uint64(1); v <= 1000; v++ {
+		if v%3 == 0 {
+			a.Add(v * 1000)
+		} else {
+			b.Add(v)
+		}
+
+		all.Add(map[bool]uint64{true: v * 1000, false: v}[v%3 == 0])
+	}
+
+	a.Merge(b)
+
+	qs := []float64{0.1, 0.5, 0.9, 0.99}
+	assert.Equal(t, all.Count(), a.Count())
+	assert.Equal(t, all.Quantiles(qs), a.Quantiles(qs))
+}
@@ -1,6 +1,8 @@
 package prober
 
 import (
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/bio-routing/matroschka-prober/pkg/measurement"
@@ -24,63 +26,259 @@ func (p *Prober) Collect(ch chan<- prometheus.Metric) {
 	defer p.targetsMu.RUnlock()
 
 	for _, t := range p.targets {
-		ts := p.lastFinishedMeasurement(t)
-		m := p.measurements.Get(ts, t)
-		if m == nil {
-			log.Debugf("Requested timestamp %d not found", ts)
+		cfg := t.Config()
+		for i, lengthMS := range cfg.Windows() {
+			ts := p.lastFinishedWindow(t, lengthMS)
+			m := p.measurements.GetWindow(ts, msToNS(lengthMS), t)
+			if m == nil {
+				log.Debugf("Requested timestamp %d not found", ts)
+				continue
+			}
 
-			return
+			w := &window{
+				t:        t,
+				lengthMS: lengthMS,
+				extra:    i > 0,
+				m:        m,
+			}
+
+			p.collectSent(ch, w)
+			p.collectReceived(ch, w)
+			p.collectRTTMin(ch, w)
+			p.collectRTTMax(ch, w)
+			p.collectRTTAvg(ch, w)
+			p.collectRTTQuantiles(ch, w)
+			p.collectJitter(ch, w)
+			p.collectVoiceQuality(ch, w)
 		}
 
-		p.collectSent(ch, m, t)
-		p.collectReceived(ch, m, t)
-		p.collectRTTMin(ch, m, t)
-		p.collectRTTMax(ch, m, t)
-		p.collectRTTAvg(ch, m, t)
-		p.collectLatePackets(ch, t)
+		p.collectRTTHistogram(ch, t)
+		p.collectTotals(ch, t)
+		p.collectDuplicatePackets(ch, t)
+		p.collectReordering(ch, t)
+		p.collectLossBursts(ch, t)
+		p.collectPathMTU(ch, t)
 	}
+}
 
+// window is the measurement of a target aggregated over a window
+type window struct {
+	t        *target.Target
+	lengthMS uint64
+	extra    bool // Window of windows_ms rather than the measurement length
+	m        *measurement.Measurement
 }
 
-func (p *Prober) collectSent(ch chan<- prometheus.Metric, m *measurement.Measurement, t *target.Target) {
-	desc := prometheus.NewDesc(metricPrefix+"packets_sent", "Sent packets", t.Labels(), nil)
-	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(m.Sent), t.LabelValues()...)
+// labels returns the labels of the metrics of the window. Only the extra windows carry a window label,
+// so the metrics of the measurement length keep their labels.
+func (w *window) labels() []string {
+	if !w.extra {
+		return w.t.Labels()
+	}
+
+	return append(w.t.Labels(), "window")
 }
 
-func (p *Prober) collectReceived(ch chan<- prometheus.Metric, m *measurement.Measurement, t *target.Target) {
-	desc := prometheus.NewDesc(metricPrefix+"packets_received", "Received packets", t.Labels(), nil)
-	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(m.Received), t.LabelValues()...)
+func (w *window) labelValues() []string {
+	if !w.extra {
+		return w.t.LabelValues()
+	}
+
+	return append(w.t.LabelValues(), windowName(w.lengthMS))
 }
 
-func (p *Prober) collectRTTMin(ch chan<- prometheus.Metric, m *measurement.Measurement, t *target.Target) {
-	desc := prometheus.NewDesc(metricPrefix+"rtt_min", "RTT Min [nanoseconds]", t.Labels(), nil)
-	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(m.RTTMin), t.LabelValues()...)
+// windowName formats the length of a window in the largest unit it is a multiple of, e.g. 1s or 5m
+func windowName(lengthMS uint64) string {
+	switch {
+	case lengthMS%uint64(time.Hour/time.Millisecond) == 0:
+		return fmt.Sprintf("%dh", lengthMS/uint64(time.Hour/time.Millisecond))
+	case lengthMS%uint64(time.Minute/time.Millisecond) == 0:
+		return fmt.Sprintf("%dm", lengthMS/uint64(time.Minute/time.Millisecond))
+	case lengthMS%uint64(time.Second/time.Millisecond) == 0:
+		return fmt.Sprintf("%ds", lengthMS/uint64(time.Second/time.Millisecond))
+	}
+
+	return fmt.Sprintf("%dms", lengthMS)
 }
 
-func (p *Prober) collectRTTMax(ch chan<- prometheus.Metric, m *measurement.Measurement, t *target.Target) {
-	desc := prometheus.NewDesc(metricPrefix+"rtt_max", "RTT Max [nanoseconds]", t.Labels(), nil)
-	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(m.RTTMax), t.LabelValues()...)
+func (p *Prober) collectSent(ch chan<- prometheus.Metric, w *window) {
+	desc := prometheus.NewDesc(metricPrefix+"packets_sent", "Sent packets in the window", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(w.m.Sent), w.labelValues()...)
 }
 
-func (p *Prober) collectRTTAvg(ch chan<- prometheus.Metric, m *measurement.Measurement, t *target.Target) {
-	desc := prometheus.NewDesc(metricPrefix+"rtt_avg", "RTT Average [nanoseconds]", t.Labels(), nil)
+func (p *Prober) collectReceived(ch chan<- prometheus.Metric, w *window) {
+	desc := prometheus.NewDesc(metricPrefix+"packets_received", "Received packets in the window", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(w.m.Received), w.labelValues()...)
+}
+
+func (p *Prober) collectRTTMin(ch chan<- prometheus.Metric, w *window) {
+	desc := prometheus.NewDesc(metricPrefix+"rtt_min", "RTT Min [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(w.m.RTTMin), w.labelValues()...)
+}
+
+func (p *Prober) collectRTTMax(ch chan<- prometheus.Metric, w *window) {
+	desc := prometheus.NewDesc(metricPrefix+"rtt_max", "RTT Max [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(w.m.RTTMax), w.labelValues()...)
+}
+
+func (p *Prober) collectRTTAvg(ch chan<- prometheus.Metric, w *window) {
+	desc := prometheus.NewDesc(metricPrefix+"rtt_avg", "RTT Average [nanoseconds]", w.labels(), nil)
 	v := float64(0)
-	if m.Received != 0 {
-		v = float64(m.RTTSum / m.Received)
+	if w.m.Received != 0 {
+		v = float64(w.m.RTTSum / w.m.Received)
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, w.labelValues()...)
+}
+
+func (p *Prober) collectRTTQuantiles(ch chan<- prometheus.Metric, w *window) {
+	qs := w.t.Config().Quantiles
+	if len(qs) == 0 {
+		return
+	}
+
+	quantiles := summaryQuantiles(w.m.Quantiles(qs), qs)
+	desc := prometheus.NewDesc(metricPrefix+"rtt", "RTT [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstSummary(desc, w.m.Received, float64(w.m.RTTSum), quantiles, w.labelValues()...)
+}
+
+// summaryQuantiles converts the values at the quantiles qs for a summary. Quantiles without a value are NaN.
+func summaryQuantiles(values map[float64]uint64, qs []float64) map[float64]float64 {
+	ret := make(map[float64]float64, len(qs))
+	for _, q := range qs {
+		v, ok := values[q]
+		if !ok {
+			// No probe made it back
+			ret[q] = math.NaN()
+			continue
+		}
+
+		ret[q] = float64(v)
+	}
+
+	return ret
+}
+
+func (p *Prober) collectJitter(ch chan<- prometheus.Metric, w *window) {
+	desc := prometheus.NewDesc(metricPrefix+"jitter_interarrival", "Interarrival jitter (RFC 3550) [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, w.m.Jitter, w.labelValues()...)
+
+	n := w.m.IPDVs.Count()
+	mean := float64(0)
+	if n != 0 {
+		mean = float64(w.m.IPDVSum) / float64(n)
+	}
+
+	desc = prometheus.NewDesc(metricPrefix+"jitter_ipdv_mean", "Mean IP packet delay variation (RFC 3393) of consecutive probes [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, mean, w.labelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"jitter_ipdv_max", "Max IP packet delay variation (RFC 3393) of consecutive probes [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(w.m.IPDVMax), w.labelValues()...)
+
+	qs := w.t.Config().Quantiles
+	if len(qs) == 0 {
+		return
+	}
+
+	desc = prometheus.NewDesc(metricPrefix+"jitter_ipdv", "IP packet delay variation (RFC 3393) of consecutive probes [nanoseconds]", w.labels(), nil)
+	ch <- prometheus.MustNewConstSummary(desc, n, float64(w.m.IPDVSum), summaryQuantiles(w.m.IPDVs.Quantiles(qs), qs), w.labelValues()...)
+}
+
+func (p *Prober) collectVoiceQuality(ch chan<- prometheus.Metric, w *window) {
+	if w.m.Sent == 0 {
+		return
+	}
+
+	rttAvg := float64(0)
+	if w.m.Received != 0 {
+		rttAvg = float64(w.m.RTTSum) / float64(w.m.Received)
 	}
-	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, t.LabelValues()...)
+
+	loss := float64(w.m.Sent-min(w.m.Received, w.m.Sent)) / float64(w.m.Sent)
+	r, mos, ok := w.t.VoiceQuality(rttAvg, w.m.Jitter, loss)
+	if !ok {
+		return
+	}
+
+	desc := prometheus.NewDesc(metricPrefix+"voice_r_factor", "E-model R-factor (ITU-T G.107) of the codec of the class", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, r, w.labelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"voice_mos", "Estimated mean opinion score of the codec of the class", w.labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, mos, w.labelValues()...)
 }
 
-func (p *Prober) collectLatePackets(ch chan<- prometheus.Metric, t *target.Target) {
-	desc := prometheus.NewDesc(metricPrefix+"late_packets_total", "Timedout but received packets", t.Labels(), nil)
-	n := t.GetLatePackets()
+func (p *Prober) collectRTTHistogram(ch chan<- prometheus.Metric, t *target.Target) {
+	count, sum, buckets, ok := t.RTTHistogram()
+	if !ok {
+		return
+	}
+
+	desc := prometheus.NewDesc(metricPrefix+"rtt_seconds", "RTT [seconds]", t.Labels(), nil)
+	ch <- prometheus.MustNewConstHistogram(desc, count, sum, buckets, t.LabelValues()...)
+}
+
+func (p *Prober) collectTotals(ch chan<- prometheus.Metric, t *target.Target) {
+	totals := t.Totals()
+
+	desc := prometheus.NewDesc(metricPrefix+"packets_sent_total", "Sent packets", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(totals.Sent), t.LabelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"packets_received_total", "Packets received within the timeout", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(totals.Received), t.LabelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"packets_timed_out_total", "Packets that were never received", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(totals.TimedOut), t.LabelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"late_packets_total", "Timedout but received packets", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(totals.Late), t.LabelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"rtt_sum_seconds_total", "Sum of the RTTs of packets received within the timeout [seconds]", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, totals.RTTSum, t.LabelValues()...)
+}
+
+func (p *Prober) collectDuplicatePackets(ch chan<- prometheus.Metric, t *target.Target) {
+	desc := prometheus.NewDesc(metricPrefix+"duplicate_packets_total", "Packets received more than once", t.Labels(), nil)
+	n := t.GetDuplicatePackets()
 	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(n), t.LabelValues()...)
 }
 
-func (p *Prober) lastFinishedMeasurement(t *target.Target) int64 {
-	measurementLengthNS := int64(t.Config().MeasurementLengthMS) * int64(time.Millisecond)
-	timeoutNS := int64(t.Config().TimeoutMS) * int64(time.Millisecond)
+func (p *Prober) collectReordering(ch chan<- prometheus.Metric, t *target.Target) {
+	reordered, extentSum := t.Reordering()
+
+	desc := prometheus.NewDesc(metricPrefix+"reordered_packets_total", "Packets received after a packet sent later (RFC 4737)", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(reordered), t.LabelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"reordering_extent_total", "Sum of the reordering extents (RFC 4737) of reordered packets", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(extentSum), t.LabelValues()...)
+}
+
+func (p *Prober) collectLossBursts(ch chan<- prometheus.Metric, t *target.Target) {
+	count, sum, buckets := t.LossBursts()
+	desc := prometheus.NewDesc(metricPrefix+"loss_burst_length", "Lengths of bursts of consecutively lost packets [packets]", t.Labels(), nil)
+	ch <- prometheus.MustNewConstHistogram(desc, count, sum, buckets, t.LabelValues()...)
+
+	desc = prometheus.NewDesc(metricPrefix+"longest_loss_burst", "Longest burst of consecutively lost packets in the last measurement window [packets]", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(t.LongestLossBurst()), t.LabelValues()...)
+}
+
+func (p *Prober) collectPathMTU(ch chan<- prometheus.Metric, t *target.Target) {
+	mtu, ok := t.PathMTU()
+	if !ok {
+		return
+	}
+
+	desc := prometheus.NewDesc(metricPrefix+"path_mtu_bytes", "Largest packet that made it back [bytes]", t.Labels(), nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(mtu), t.LabelValues()...)
+}
+
+// lastFinishedWindow returns the start of the last window of length lengthMS whose probes all arrived or timed out
+func (p *Prober) lastFinishedWindow(t *target.Target, lengthMS uint64) int64 {
+	lengthNS := msToNS(lengthMS)
+	timeoutNS := msToNS(t.Config().TimeoutMS)
 	nowNS := p.clock.Now().UnixNano()
-	ts := nowNS - timeoutNS - measurementLengthNS
-	return ts - ts%measurementLengthNS
+	ts := nowNS - timeoutNS - lengthNS
+	return ts - ts%lengthNS
+}
+
+func msToNS(ms uint64) int64 {
+	return int64(ms) * int64(time.Millisecond)
 }
@@ -0,0 +1,60 @@
+package prober
+
+import (
+	"net"
+	"testing"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/bio-routing/matroschka-prober/pkg/target"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowLabels(t *testing.T) {
+	tgt, err := target.NewTarget(target.TargetConfig{
+		Name: "path01",
+		TOS:  target.TOS{Name: "BE"},
+		Hops: []config.Hop{
+			{
+				SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+				DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+			},
+		},
+		SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+		MeasurementLengthMS: 1000,
+		TimeoutMS:           500,
+		WindowsMS:           []uint64{300000},
+	}, net.ParseIP("128.0.0.1"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		w              *window
+		expectedLabels []string
+		expectedValues []string
+	}{
+		{
+			name: "measurement length has no window label",
+			w: &window{
+				t:        tgt,
+				lengthMS: 1000,
+			},
+			expectedLabels: []string{"tos", "path"},
+			expectedValues: []string{"BE", "path01"},
+		},
+		{
+			name: "extra window",
+			w: &window{
+				t:        tgt,
+				lengthMS: 300000,
+				extra:    true,
+			},
+			expectedLabels: []string{"tos", "path", "window"},
+			expectedValues: []string{"BE", "path01", "5m"},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expectedLabels, test.w.labels(), test.name)
+		assert.Equal(t, test.expectedValues, test.w.labelValues(), test.name)
+	}
+}
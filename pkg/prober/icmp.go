@@ -0,0 +1,155 @@
+package prober
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	icmpProtocolNumber   = 1
+	icmpv6ProtocolNumber = 58
+
+	// icmpFragmentationNeeded is the code of a destination unreachable message asking for fragmentation
+	icmpFragmentationNeeded = 4
+)
+
+// updateICMPSockets opens the ICMP sockets while a target sweeps the MTU and closes them otherwise,
+// so probers without MTU sweeps do not read all ICMP traffic of the host. Must be called with targetsMu held.
+func (p *Prober) updateICMPSockets() {
+	if !p.sweepsMTU() {
+		p.closeICMPSockets()
+		return
+	}
+
+	if p.icmpConn4 != nil {
+		return
+	}
+
+	err := p.initICMPSockets()
+	if err != nil {
+		log.Errorf("Unable to initialize ICMP sockets, MTU sweeps run without ICMP hints: %v", err)
+		return
+	}
+
+	go p.icmpReceiver(p.icmpConn4, icmpProtocolNumber)
+	go p.icmpReceiver(p.icmpConn6, icmpv6ProtocolNumber)
+}
+
+func (p *Prober) sweepsMTU() bool {
+	for _, t := range p.targets {
+		if t.DontFragment() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Prober) initICMPSockets() error {
+	c4, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return fmt.Errorf("unable to listen for ICMP packets: %v", err)
+	}
+
+	c6, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		c4.Close()
+		return fmt.Errorf("unable to listen for ICMPv6 packets: %v", err)
+	}
+
+	p.icmpConn4 = c4
+	p.icmpConn6 = c6
+
+	return nil
+}
+
+func (p *Prober) closeICMPSockets() {
+	if p.icmpConn4 == nil {
+		return
+	}
+
+	p.icmpConn4.Close()
+	p.icmpConn6.Close()
+	p.icmpConn4 = nil
+	p.icmpConn6 = nil
+}
+
+// icmpReceiver reads ICMP messages that report a too small MTU and hands them as hints to the MTU sweeping targets
+func (p *Prober) icmpReceiver(conn *icmp.PacketConn, proto int) {
+	defer conn.Close()
+
+	recvBuffer := make([]byte, mtuMax)
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		n, _, err := conn.ReadFrom(recvBuffer)
+		if errors.Is(err, net.ErrClosed) {
+			// No target sweeps the MTU anymore
+			return
+		}
+
+		if err != nil {
+			log.Errorf("Unable to read from ICMP socket: %v", err)
+			return
+		}
+
+		dst, mtu, ok := parseMTUMessage(recvBuffer[:n], proto)
+		if !ok {
+			continue
+		}
+
+		p.targetsMu.RLock()
+		for _, t := range p.targets {
+			t.MTUHint(dst, mtu)
+		}
+		p.targetsMu.RUnlock()
+	}
+}
+
+// parseMTUMessage returns the destination of the offending packet and the reported MTU of a
+// fragmentation needed (ICMP) or packet too big (ICMPv6) message
+func parseMTUMessage(b []byte, proto int) (net.IP, uint64, bool) {
+	msg, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	switch body := msg.Body.(type) {
+	case *icmp.DstUnreach:
+		if msg.Type != ipv4.ICMPTypeDestinationUnreachable || msg.Code != icmpFragmentationNeeded || len(b) < 8 {
+			return nil, 0, false
+		}
+
+		hdr, err := ipv4.ParseHeader(body.Data)
+		if err != nil {
+			return nil, 0, false
+		}
+
+		return hdr.Dst, uint64(binary.BigEndian.Uint16(b[6:8])), true
+	case *icmp.PacketTooBig:
+		if len(body.Data) < ipv6.HeaderLen {
+			return nil, 0, false
+		}
+
+		hdr, err := ipv6.ParseHeader(body.Data)
+		if err != nil {
+			return nil, 0, false
+		}
+
+		return hdr.Dst, uint64(body.MTU), true
+	}
+
+	return nil, 0, false
+}
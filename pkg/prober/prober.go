@@ -8,6 +8,7 @@ import (
 
 	"github.com/bio-routing/matroschka-prober/pkg/measurement"
 	"github.com/bio-routing/matroschka-prober/pkg/target"
+	"golang.org/x/net/icmp"
 )
 
 type Prober struct {
@@ -19,7 +20,10 @@ type Prober struct {
 	proberAddr6       net.IP
 	basePort          uint16
 	udpPort           uint16
-	udpConn           udpSocket // Used to receive returning packets
+	udpConn           udpSocket        // Used to receive returning IPv4 packets
+	udpConn6          udpSocket        // Used to receive returning IPv6 packets
+	icmpConn4         *icmp.PacketConn // Used to receive MTU hints for IPv4, only open while a target sweeps the MTU
+	icmpConn6         *icmp.PacketConn // Used to receive MTU hints for IPv6, only open while a target sweeps the MTU
 	probesReceived    uint64
 	probesSent        uint64
 	targets           map[target.TargetID]*target.Target
@@ -51,25 +55,94 @@ func New(pps uint64, basePort uint16, proberAddr4 net.IP, proberAddr6 net.IP, me
 	return pr
 }
 
+// Configuration is a set of targets prepared for a prober. It is applied with Commit.
+type Configuration struct {
+	p       *Prober
+	targets map[target.TargetID]*target.Target
+}
+
+// Configure replaces the targets of the prober
 func (p *Prober) Configure(targetConfigs []target.TargetConfig) error {
-	p.targetsMu.Lock()
-	defer p.targetsMu.Unlock()
+	c, err := p.Prepare(targetConfigs)
+	if err != nil {
+		return err
+	}
+
+	c.Commit()
+	return nil
+}
+
+// Prepare builds the targets of a new configuration without touching the running one.
+// Targets whose config did not change are taken over with their state.
+func (p *Prober) Prepare(targetConfigs []target.TargetConfig) (*Configuration, error) {
+	p.targetsMu.RLock()
+	defer p.targetsMu.RUnlock()
+
+	c := &Configuration{
+		p:       p,
+		targets: make(map[target.TargetID]*target.Target, len(targetConfigs)),
+	}
 
-	p.targets = make(map[target.TargetID]*target.Target, len(targetConfigs))
 	for _, tc := range targetConfigs {
-		laddr, err := getLocalAddr(tc.Hops[0].GetAddr(0))
+		id := tc.GetID()
+		if old, ok := p.targets[id]; ok {
+			oldCfg := old.Config()
+			if oldCfg.Equal(&tc) {
+				// Unchanged targets keep their in flight probes, measurements and counters
+				c.targets[id] = old
+				continue
+			}
+		}
+
+		laddr, err := p.getReturnAddr(tc)
 		if err != nil {
-			return fmt.Errorf("unable to get local address for target %q: %v", tc.Name, err)
+			return nil, fmt.Errorf("unable to get local address for target %q: %v", tc.Name, err)
 		}
 
 		t, err := target.NewTarget(tc, laddr)
 		if err != nil {
-			return fmt.Errorf("unable to create target %q: %v", tc.Name, err)
+			return nil, fmt.Errorf("unable to create target %q: %v", tc.Name, err)
 		}
-		p.targets[tc.GetID()] = t
+
+		c.targets[id] = t
 	}
 
-	return nil
+	return c, nil
+}
+
+// Commit swaps in the targets of the configuration
+func (c *Configuration) Commit() {
+	p := c.p
+
+	p.targetsMu.Lock()
+	defer p.targetsMu.Unlock()
+
+	for id, old := range p.targets {
+		if c.targets[id] != old {
+			p.measurements.Remove(old)
+		}
+	}
+
+	p.targets = c.targets
+	p.updateICMPSockets()
+}
+
+// getReturnAddr returns the address probes of a target return to
+func (p *Prober) getReturnAddr(tc target.TargetConfig) (net.IP, error) {
+	if tc.ReturnAFI == tc.FirstHopAFI() {
+		return getLocalAddr(tc.Hops[0].GetAddr(0))
+	}
+
+	addr := p.proberAddr4
+	if tc.ReturnAFI == 6 {
+		addr = p.proberAddr6
+	}
+
+	if addr == nil {
+		return nil, fmt.Errorf("return_afi %d differs from the first hop and requires an IPv%d address on the src_interface", tc.ReturnAFI, tc.ReturnAFI)
+	}
+
+	return addr, nil
 }
 
 func getLocalAddr(dest net.IP) (net.IP, error) {
@@ -97,35 +170,19 @@ func (p *Prober) Start() error {
 
 	go p.rttTimeoutChecker()
 	go p.sender()
-	go p.receiver()
-	go p.cleaner()
+	go p.receiver(p.udpConn)
+	go p.receiver(p.udpConn6)
 	return nil
 }
 
 // Stop stops the prober
 func (p *Prober) Stop() {
 	close(p.stop)
-}
 
-func (p *Prober) cleaner() {
-	for {
-		select {
-		case <-p.stop:
-			return
-		default:
-			time.Sleep(time.Second)
-			p.cleanup()
-		}
-	}
-}
-
-func (p *Prober) cleanup() {
 	p.targetsMu.Lock()
 	defer p.targetsMu.Unlock()
 
-	for _, t := range p.targets {
-		p.measurements.RemoveOlder(p.lastFinishedMeasurement(t))
-	}
+	p.closeICMPSockets()
 }
 
 func (p *Prober) init() error {
@@ -0,0 +1,124 @@
+package prober
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/bio-routing/matroschka-prober/pkg/target"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureKeepsTotals(t *testing.T) {
+	cfg := &config.Config{
+		Paths: []config.Path{
+			{
+				Name: "path01",
+				Hops: []string{"router01"},
+				Labels: map[string]string{
+					"site":   "fra01",
+					"region": "eu",
+					"tier":   "core",
+					"vendor": "acme",
+				},
+			},
+		},
+		Routers: []config.Router{
+			{
+				Name:        "router01",
+				DstRangeStr: "127.0.0.1/32",
+				SrcRangeStr: "127.0.0.2/32",
+			},
+		},
+	}
+
+	err := cfg.ApplyDefaults()
+	assert.NoError(t, err)
+	err = cfg.ConvertIPAddresses()
+	assert.NoError(t, err)
+
+	targetConfigs := func() []target.TargetConfig {
+		tcs, err := target.Targets(cfg.Paths[0], cfg)
+		assert.NoError(t, err)
+
+		return tcs
+	}
+
+	p := New(25, 32768, nil, nil, time.Second, 0)
+	err = p.Configure(targetConfigs())
+	assert.NoError(t, err)
+
+	for _, tgt := range p.targets {
+		tgt.ProbeSent()
+		tgt.ProbeReturned(uint64(time.Millisecond))
+	}
+
+	// Reloading an unchanged path must not reset its counters
+	for range 20 {
+		err = p.Configure(targetConfigs())
+		assert.NoError(t, err)
+
+		for _, tgt := range p.targets {
+			assert.Equal(t, target.Totals{
+				Sent:     1,
+				Received: 1,
+				RTTSum:   0.001,
+			}, tgt.Totals())
+		}
+	}
+}
+
+func TestStopClosesICMPSockets(t *testing.T) {
+	cfg := &config.Config{
+		Paths: []config.Path{
+			{
+				Name:     "path01",
+				Hops:     []string{"router01"},
+				MTUSweep: &config.MTUSweep{},
+			},
+		},
+		Routers: []config.Router{
+			{
+				Name:        "router01",
+				DstRangeStr: "127.0.0.1/32",
+				SrcRangeStr: "127.0.0.2/32",
+			},
+		},
+	}
+
+	err := cfg.ApplyDefaults()
+	assert.NoError(t, err)
+	err = cfg.ConvertIPAddresses()
+	assert.NoError(t, err)
+
+	tcs, err := target.Targets(cfg.Paths[0], cfg)
+	assert.NoError(t, err)
+
+	// The descriptor of the network poller stays open once it is initialized
+	_, err = getLocalAddr(net.ParseIP("127.0.0.1"))
+	assert.NoError(t, err)
+	fdsBefore := openFDs(t)
+
+	p := New(25, 32768, nil, nil, time.Second, 0)
+	err = p.Configure(tcs)
+	assert.NoError(t, err)
+	if p.icmpConn4 == nil {
+		t.Skip("raw ICMP sockets require CAP_NET_RAW")
+	}
+
+	p.Stop()
+	assert.Nil(t, p.icmpConn4)
+	assert.Nil(t, p.icmpConn6)
+	assert.Equal(t, fdsBefore, openFDs(t))
+}
+
+func openFDs(t *testing.T) int {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("unable to list open file descriptors: %v", err)
+	}
+
+	return len(fds)
+}
@@ -12,8 +12,8 @@ const (
 	mtuMax = uint16(9216)
 )
 
-func (p *Prober) receiver() {
-	defer p.udpConn.Close()
+func (p *Prober) receiver(udpConn udpSocket) {
+	defer udpConn.Close()
 
 	recvBuffer := make([]byte, mtuMax)
 	for {
@@ -23,7 +23,7 @@ func (p *Prober) receiver() {
 		default:
 		}
 
-		_, ts, err := p.udpConn.Read(recvBuffer)
+		_, ts, err := udpConn.Read(recvBuffer)
 		if ts == nil {
 			now := time.Now()
 			ts = &now
@@ -42,12 +42,22 @@ func (p *Prober) receiver() {
 			return
 		}
 
-		target, err := p.transitProbes.remove(pkt.SequenceNumber)
+		tp, err := p.transitProbes.receive(pkt.SequenceNumber)
 		if err != nil {
-			// Probe was count as lost, so we ignore it from here on
+			// Probe arrived before or was count as lost, so we ignore it from here on
+			t := p.transitProbes.sender(pkt.SequenceNumber)
+			if t != nil && t.Duplicate(pkt.TargetSequenceNumber) {
+				t.DuplicatePacket()
+			}
+
 			continue
 		}
 
+		target := tp.target
+		target.Duplicate(pkt.TargetSequenceNumber)
+		target.ProbeReceived(tp.size)
+		target.ProbeArrived(pkt.TargetSequenceNumber)
+
 		rtt := ts.UnixNano() - pkt.TimeStampUnixNano
 		if target.TimedOut(rtt) {
 			// Probe arrived late. rttTimoutChecker() will clean up after it. So we ignore it from here on
@@ -55,6 +65,7 @@ func (p *Prober) receiver() {
 			continue
 		}
 
-		p.measurements.AddRecv(pkt.TimeStampUnixNano, uint64(rtt), target)
+		target.ProbeReturned(uint64(rtt))
+		p.measurements.AddRecv(pkt.TimeStampUnixNano, pkt.TargetSequenceNumber, uint64(rtt), target)
 	}
 }
@@ -1,6 +1,7 @@
 package prober
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"sync/atomic"
@@ -17,6 +18,7 @@ func (p *Prober) sender() {
 	defer p.rawConn6.Close()
 
 	seq := uint64(0)
+	tick := uint64(0)
 	pr := target.Probe{}
 
 	ticker := time.NewTicker(time.Second / time.Duration(p.pps))
@@ -31,26 +33,37 @@ func (p *Prober) sender() {
 		p.targetsMu.RLock()
 		for _, target := range p.targets {
 			tCfg := target.Config()
+			if !tCfg.SendAt(tick) {
+				continue
+			}
 
 			srcAddr := tCfg.GetSrcAddr(seq)
 			dstAddr := tCfg.Hops[0].GetAddr(seq)
 
+			size := target.PayloadSize()
 			pr.SequenceNumber = seq
 			pr.TimeStampUnixNano = time.Now().UnixNano()
-			pkt, err := target.CraftPacket(pr, p.udpPort)
+			pr.TargetSequenceNumber = target.NextSequenceNumber()
+			pkt, err := target.CraftPacket(pr, p.udpPort, size)
 			if err != nil {
 				log.Errorf("Unable to craft packet: %v", err)
 				continue
 			}
 
-			p.transitProbes.add(target, &pr)
+			p.transitProbes.add(target, &pr, size)
 
 			tsAligned := pr.TimeStampUnixNano - (pr.TimeStampUnixNano % (int64(tCfg.MeasurementLengthMS) * int64(time.Millisecond)))
 			p.measurements.AddSent(target, tsAligned)
 
-			err = p.sendPacket(pkt, srcAddr, dstAddr, tCfg.TOS.Value)
+			err = p.sendPacket(pkt, srcAddr, dstAddr, tCfg.TOS.Value, int64(target.FirstHopProtocol()), target.DontFragment())
 			if err != nil {
-				log.Errorf("Unable to send packet: %v", err)
+				if target.DontFragment() && errors.Is(err, unix.EMSGSIZE) {
+					// Probe exceeds the MTU towards the first hop
+					target.ProbeLost(size)
+				} else {
+					log.Errorf("Unable to send packet: %v", err)
+				}
+
 				_, err = p.transitProbes.remove(pr.SequenceNumber)
 				if err != nil {
 					log.Errorf("unable to remove transit probe %d: %v", pr.SequenceNumber, err)
@@ -59,31 +72,35 @@ func (p *Prober) sender() {
 				continue
 			}
 
+			target.AdvanceSequenceNumber()
+			target.ProbeSent()
 			atomic.AddUint64(&p.probesSent, 1)
 			seq++
 		}
 		p.targetsMu.RUnlock()
+		tick++
 	}
 }
 
-func (p *Prober) sendPacket(payload []byte, src net.IP, dst net.IP, tos uint8) error {
+func (p *Prober) sendPacket(payload []byte, src net.IP, dst net.IP, tos uint8, protocol int64, dontFragment bool) error {
 	options := writeOptions{
-		src:      src,
-		dst:      dst,
-		tos:      int64(tos),
-		ttl:      ttl,
-		protocol: unix.IPPROTO_GRE,
+		src:          src,
+		dst:          dst,
+		tos:          int64(tos),
+		ttl:          ttl,
+		protocol:     protocol,
+		dontFragment: dontFragment,
 	}
 
 	if dst.To4() != nil {
 		if err := p.rawConn4.WriteTo(payload, options); err != nil {
-			return fmt.Errorf("unable to send ipv4 packet: %v", err)
+			return fmt.Errorf("unable to send ipv4 packet: %w", err)
 		}
 		return nil
 	}
 
 	if err := p.rawConn6.WriteTo(payload, options); err != nil {
-		return fmt.Errorf("unable to send ipv6 packet: %v", err)
+		return fmt.Errorf("unable to send ipv6 packet: %w", err)
 	}
 
 	return nil
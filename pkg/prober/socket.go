@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -21,11 +22,12 @@ type rawSocket interface {
 }
 
 type writeOptions struct {
-	src      net.IP
-	dst      net.IP
-	tos      int64
-	ttl      int64
-	protocol int64
+	src          net.IP
+	dst          net.IP
+	tos          int64
+	ttl          int64
+	protocol     int64
+	dontFragment bool
 }
 
 type udpSocket interface {
@@ -37,6 +39,7 @@ type rawSockWrapper struct {
 	rawConn *ipv4.RawConn
 }
 
+// newRawSockWrapper creates a raw IPv4 socket. As the IP header is included, packets of any protocol can be sent.
 func newRawSockWrapper() (*rawSockWrapper, error) {
 	greProtoStr := strconv.FormatInt(unix.IPPROTO_GRE, 10)
 	c, err := net.ListenPacket("ip4:"+greProtoStr, "0.0.0.0") // GRE for IPv4
@@ -63,8 +66,12 @@ func (s *rawSockWrapper) WriteTo(p []byte, o writeOptions) error {
 		TOS:      int(o.tos),
 		TotalLen: ipv4.HeaderLen + len(p),
 		TTL:      ttl,
-		Protocol: unix.IPPROTO_GRE,
+		Protocol: int(o.protocol),
 	}
+	if o.dontFragment {
+		iph.Flags = ipv4.DontFragment
+	}
+
 	cm := &ipv4.ControlMessage{}
 	if o.src != nil {
 		cm.Src = o.src
@@ -82,12 +89,32 @@ type udpSockWrapper struct {
 	port   uint16
 }
 
-func newUDPSockWrapper(port uint16, rmem int) (*udpSockWrapper, error) {
-	sockfd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, unix.IPPROTO_UDP)
+func newUDPSockWrapper(port uint16, rmem int, afi uint8) (*udpSockWrapper, error) {
+	domain := unix.AF_INET
+	var sa unix.Sockaddr = &unix.SockaddrInet4{
+		Port: int(port),
+	}
+	if afi == 6 {
+		domain = unix.AF_INET6
+		sa = &unix.SockaddrInet6{
+			Port: int(port),
+		}
+	}
+
+	sockfd, err := unix.Socket(domain, unix.SOCK_DGRAM, unix.IPPROTO_UDP)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create UDP socket: %v", err)
 	}
 
+	if afi == 6 {
+		// IPv4 packets are received by the IPv4 socket bound to the same port
+		err = unix.SetsockoptInt(sockfd, unix.IPPROTO_IPV6, unix.IPV6_V6ONLY, 1)
+		if err != nil {
+			unix.Close(sockfd)
+			return nil, fmt.Errorf("unable to set IPV6_V6ONLY on UDP socket: %v", err)
+		}
+	}
+
 	err = unix.SetsockoptInt(sockfd, unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
 	if err != nil {
 		return nil, fmt.Errorf("unable to set SO_TIMESTAMP on UDP socket: %v", err)
@@ -100,10 +127,9 @@ func newUDPSockWrapper(port uint16, rmem int) (*udpSockWrapper, error) {
 		}
 	}
 
-	err = unix.Bind(sockfd, &unix.SockaddrInet4{
-		Port: int(port),
-	})
+	err = unix.Bind(sockfd, sa)
 	if err != nil {
+		unix.Close(sockfd)
 		return nil, fmt.Errorf("unable to bind UDP socket to port %d: %v", port, err)
 	}
 
@@ -163,24 +189,45 @@ func (p *Prober) initRawSocket() error {
 
 func (p *Prober) initUDPSocket() error {
 	for i := range maxPort {
-		s, err := newUDPSockWrapper(p.basePort+uint16(i), p.rmem)
+		s, err := newUDPSockWrapper(p.basePort+uint16(i), p.rmem, 4)
+		if err != nil {
+			continue
+		}
+
+		s6, err := newUDPSockWrapper(p.basePort+uint16(i), p.rmem, 6)
 		if err != nil {
+			s.Close()
 			continue
 		}
 
 		p.udpPort = p.basePort + uint16(i)
 		p.udpConn = s
+		p.udpConn6 = s6
 		return nil
 	}
 
 	return fmt.Errorf("unable to find free UDP port")
 }
 
+// rawIPv6SocketWrapper sends IPv6 packets. The kernel builds the IPv6 header, so there is one socket per protocol.
+// Segment routed probes are sent on a socket of the routing header protocol (43) with the segment routing header as payload.
+// Packets that must not be fragmented, e.g. of MTU sweeps, are sent on separate sockets with IPV6_DONTFRAG set.
 type rawIPv6SocketWrapper struct {
-	rawIPv6Conn *ipv6.PacketConn
+	rawIPv6Conns map[rawIPv6ConnKey]*ipv6.PacketConn
+	l            sync.Mutex
+}
+
+type rawIPv6ConnKey struct {
+	protocol     int64
+	dontFragment bool
 }
 
 func (s *rawIPv6SocketWrapper) WriteTo(p []byte, o writeOptions) error {
+	rc, err := s.getConn(o.protocol, o.dontFragment)
+	if err != nil {
+		return fmt.Errorf("unable to get socket for protocol %d: %w", o.protocol, err)
+	}
+
 	cm := &ipv6.ControlMessage{
 		TrafficClass: int(o.tos),
 		HopLimit:     ttl,
@@ -190,24 +237,95 @@ func (s *rawIPv6SocketWrapper) WriteTo(p []byte, o writeOptions) error {
 
 	dstAddress := net.IPAddr{IP: o.dst}
 
-	_, err := s.rawIPv6Conn.WriteTo(p, cm, &dstAddress)
+	_, err = rc.WriteTo(p, cm, &dstAddress)
 	return err
 }
 
+func (s *rawIPv6SocketWrapper) getConn(protocol int64, dontFragment bool) (*ipv6.PacketConn, error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	key := rawIPv6ConnKey{
+		protocol:     protocol,
+		dontFragment: dontFragment,
+	}
+	if rc, ok := s.rawIPv6Conns[key]; ok {
+		return rc, nil
+	}
+
+	rc, err := listenIPv6Raw(protocol, dontFragment)
+	if err != nil {
+		return nil, err
+	}
+
+	s.rawIPv6Conns[key] = rc
+	return rc, nil
+}
+
 func (s *rawIPv6SocketWrapper) Close() error {
-	return s.rawIPv6Conn.Close()
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	var ret error
+	for _, rc := range s.rawIPv6Conns {
+		err := rc.Close()
+		if err != nil {
+			ret = err
+		}
+	}
+
+	return ret
 }
 
 func newIPv6RawSockWrapper() (*rawIPv6SocketWrapper, error) {
-	greProtoStr := strconv.FormatInt(unix.IPPROTO_GRE, 10)
-	c, err := net.ListenPacket("ip6:"+greProtoStr, "::")
+	rc, err := listenIPv6Raw(unix.IPPROTO_GRE, false)
 	if err != nil {
-		return nil, fmt.Errorf("unable to listen for GRE packets: %v", err)
+		return nil, err
 	}
 
-	rc := ipv6.NewPacketConn(c)
-
 	return &rawIPv6SocketWrapper{
-		rawIPv6Conn: rc,
+		rawIPv6Conns: map[rawIPv6ConnKey]*ipv6.PacketConn{
+			{protocol: unix.IPPROTO_GRE}: rc,
+		},
 	}, nil
 }
+
+func listenIPv6Raw(protocol int64, dontFragment bool) (*ipv6.PacketConn, error) {
+	protoStr := strconv.FormatInt(protocol, 10)
+	c, err := net.ListenPacket("ip6:"+protoStr, "::")
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen for protocol %d packets: %v", protocol, err)
+	}
+
+	if dontFragment {
+		err = setIPv6DontFrag(c.(*net.IPConn))
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return ipv6.NewPacketConn(c), nil
+}
+
+// setIPv6DontFrag makes the kernel fail sends of packets larger than the MTU with EMSGSIZE instead of fragmenting them
+func setIPv6DontFrag(c *net.IPConn) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("unable to get raw connection: %v", err)
+	}
+
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_DONTFRAG, 1)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to control socket: %v", err)
+	}
+
+	if sockErr != nil {
+		return fmt.Errorf("unable to set IPV6_DONTFRAG: %v", sockErr)
+	}
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package prober
 
 import (
+	"cmp"
+	"slices"
 	"time"
 
+	"github.com/bio-routing/matroschka-prober/pkg/target"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,12 +19,37 @@ func (p *Prober) rttTimeoutChecker() {
 		case <-t.C:
 			now := time.Now()
 			maxTS := now.Add(-3 * p.measurementLength)
+			expired := make(map[*target.Target][]transitProbe)
 			for _, seq := range p.transitProbes.getLt(maxTS) {
-				_, err := p.transitProbes.remove(seq)
+				tp, err := p.transitProbes.remove(seq)
 				if err != nil {
 					log.Infof("Probe %d timeouted: Unable to remove: %v", seq, err)
+					continue
 				}
+
+				expired[tp.target] = append(expired[tp.target], tp)
+				tp.target.ProbeTimedOut()
+				tp.target.ProbeLost(tp.size)
+			}
+
+			for _, tp := range p.transitProbes.removeReceivedLt(maxTS) {
+				expired[tp.target] = append(expired[tp.target], tp)
+			}
+
+			for t, tps := range expired {
+				trackLossBursts(t, tps)
 			}
 		}
 	}
 }
+
+// trackLossBursts passes the expired probes of a target in order to its loss burst tracking
+func trackLossBursts(t *target.Target, tps []transitProbe) {
+	slices.SortFunc(tps, func(a, b transitProbe) int {
+		return cmp.Compare(a.targetSeq, b.targetSeq)
+	})
+
+	for _, tp := range tps {
+		t.ProbeExpired(tp.timestamp, !tp.received)
+	}
+}
@@ -1,6 +1,7 @@
 package prober
 
 import (
+	"container/heap"
 	"fmt"
 	"sync"
 	"time"
@@ -8,38 +9,94 @@ import (
 	"github.com/bio-routing/matroschka-prober/pkg/target"
 )
 
+const (
+	// senderHistory is the number of the latest sent probes whose target is known after they left transit
+	senderHistory = 1 << 14
+)
+
 type transitProbe struct {
 	target    *target.Target
 	timestamp int64
+	size      uint64
+	targetSeq uint64
+	received  bool
 }
 
 type transitProbes struct {
 	m map[uint64]transitProbe // index is the sequence number
-	l sync.RWMutex
+	// received holds the probes that left m on arrival until they time out, so loss bursts are tracked in order
+	received receivedProbes
+	// senders is a ring buffer of the targets of the latest sent probes to attribute duplicates to them
+	senders []sentProbe
+	l       sync.RWMutex
+}
+
+type sentProbe struct {
+	seq    uint64
+	target *target.Target
 }
 
-func (t *transitProbes) add(target *target.Target, p *target.Probe) {
+func (t *transitProbes) add(target *target.Target, p *target.Probe, size uint64) {
 	t.l.Lock()
 	defer t.l.Unlock()
 	t.m[p.SequenceNumber] = transitProbe{
 		target:    target,
 		timestamp: p.TimeStampUnixNano,
+		size:      size,
+		targetSeq: p.TargetSequenceNumber,
+	}
+	t.senders[p.SequenceNumber%senderHistory] = sentProbe{
+		seq:    p.SequenceNumber,
+		target: target,
 	}
 }
 
-func (t *transitProbes) remove(seq uint64) (*target.Target, error) {
+func (t *transitProbes) remove(seq uint64) (transitProbe, error) {
 	t.l.Lock()
 
 	if _, ok := t.m[seq]; !ok {
 		t.l.Unlock()
-		return nil, fmt.Errorf("sequence number %d not found", seq)
+		return transitProbe{}, fmt.Errorf("sequence number %d not found", seq)
 	}
 
 	tp := t.m[seq]
 	delete(t.m, seq)
 	t.l.Unlock()
 
-	return tp.target, nil
+	return tp, nil
+}
+
+// receive removes the probe from transit and marks it as received
+func (t *transitProbes) receive(seq uint64) (transitProbe, error) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	tp, ok := t.m[seq]
+	if !ok {
+		return transitProbe{}, fmt.Errorf("sequence number %d not found", seq)
+	}
+
+	delete(t.m, seq)
+	tp.received = true
+	heap.Push(&t.received, receivedProbe{
+		seq: seq,
+		tp:  tp,
+	})
+
+	return tp, nil
+}
+
+// sender returns the target of the probe or nil if it is not among the latest sent probes
+func (t *transitProbes) sender(seq uint64) *target.Target {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	s := t.senders[seq%senderHistory]
+	if s.seq != seq {
+		return nil
+	}
+
+	return s.target
 }
 
 func (t *transitProbes) getLt(lt time.Time) []uint64 {
@@ -56,8 +113,55 @@ func (t *transitProbes) getLt(lt time.Time) []uint64 {
 	return ret
 }
 
+// removeReceivedLt removes the received probes sent before lt
+func (t *transitProbes) removeReceivedLt(lt time.Time) []transitProbe {
+	ret := make([]transitProbe, 0)
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	// Sequence numbers are assigned in the order the probes are sent
+	for len(t.received) > 0 && t.received[0].tp.timestamp < lt.UnixNano() {
+		ret = append(ret, heap.Pop(&t.received).(receivedProbe).tp)
+	}
+
+	return ret
+}
+
 func newTransitProbes() *transitProbes {
 	return &transitProbes{
-		m: make(map[uint64]transitProbe),
+		m:       make(map[uint64]transitProbe),
+		senders: make([]sentProbe, senderHistory),
 	}
 }
+
+type receivedProbe struct {
+	seq uint64
+	tp  transitProbe
+}
+
+// receivedProbes is a min heap of received probes by sequence number
+type receivedProbes []receivedProbe
+
+func (r receivedProbes) Len() int {
+	return len(r)
+}
+
+func (r receivedProbes) Less(i, j int) bool {
+	return r[i].seq < r[j].seq
+}
+
+func (r receivedProbes) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+func (r *receivedProbes) Push(x any) {
+	*r = append(*r, x.(receivedProbe))
+}
+
+func (r *receivedProbes) Pop() any {
+	old := *r
+	x := old[len(old)-1]
+	*r = old[:len(old)-1]
+
+	return x
+}
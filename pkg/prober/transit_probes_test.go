@@ -0,0 +1,44 @@
+package prober
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bio-routing/matroschka-prober/pkg/target"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransitProbes(t *testing.T) {
+	ta := &target.Target{}
+	tps := newTransitProbes()
+	for seq := uint64(0); seq < 4; seq++ {
+		tps.add(ta, &target.Probe{
+			SequenceNumber:       seq,
+			TimeStampUnixNano:    int64(seq),
+			TargetSequenceNumber: seq,
+		}, 100)
+	}
+
+	// Received probes leave transit right away
+	for _, seq := range []uint64{2, 0} {
+		tp, err := tps.receive(seq)
+		assert.NoError(t, err)
+		assert.True(t, tp.received)
+	}
+
+	_, err := tps.receive(2)
+	assert.Error(t, err, "received twice")
+	assert.Equal(t, ta, tps.sender(2), "sender of a received probe")
+	assert.Nil(t, tps.sender(senderHistory+2), "sender of an unknown probe")
+	assert.ElementsMatch(t, []uint64{1, 3}, tps.getLt(time.Unix(0, 4)))
+
+	// Received probes are kept in order until they time out
+	received := tps.removeReceivedLt(time.Unix(0, 2))
+	assert.Len(t, received, 1)
+	assert.Equal(t, uint64(0), received[0].targetSeq)
+
+	received = tps.removeReceivedLt(time.Unix(0, 4))
+	assert.Len(t, received, 1)
+	assert.Equal(t, uint64(2), received[0].targetSeq)
+	assert.Empty(t, tps.removeReceivedLt(time.Unix(0, 4)))
+}
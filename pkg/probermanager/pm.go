@@ -2,8 +2,11 @@ package probermanager
 
 import (
 	"fmt"
+	"hash/fnv"
+	"maps"
 	"net"
 	"runtime"
+	"slices"
 	"sync"
 	"time"
 
@@ -16,6 +19,7 @@ import (
 type ProberManager struct {
 	probers     map[uint64][]*prober.Prober
 	probersMu   sync.RWMutex
+	configureMu sync.Mutex
 	basePort    uint16
 	proberAddr4 net.IP
 	proberAddr6 net.IP
@@ -34,103 +38,141 @@ func New(basePort uint16, proberAddr4 net.IP, proberAddr6 net.IP, timeout time.D
 	}
 }
 
-func (pm *ProberManager) GetProbers(pps uint64) ([]*prober.Prober, error) {
-	pm.probersMu.Lock()
-	defer pm.probersMu.Unlock()
+// getProbers returns the probers of a PPS rate. Probers of a new rate are created but not started. The bool tells if they are new.
+func (pm *ProberManager) getProbers(pps uint64) ([]*prober.Prober, bool) {
+	pm.probersMu.RLock()
+	defer pm.probersMu.RUnlock()
 
 	if p, ok := pm.probers[pps]; ok {
-		return p, nil
+		return p, false
 	}
 
-	pm.probers[pps] = make([]*prober.Prober, 0, runtime.GOMAXPROCS(0))
+	probers := make([]*prober.Prober, 0, runtime.GOMAXPROCS(0))
 	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
-		p := prober.New(pps, pm.basePort, pm.proberAddr4, pm.proberAddr6, pm.timeout, pm.rmem)
-		err := p.Start()
-		if err != nil {
-			return nil, fmt.Errorf("unable to start prober: %v", err)
-		}
-
-		pm.probers[pps] = append(pm.probers[pps], p)
+		probers = append(probers, prober.New(pps, pm.basePort, pm.proberAddr4, pm.proberAddr6, pm.timeout, pm.rmem))
 	}
 
-	return pm.probers[pps], nil
+	return probers, true
 }
 
+// Configure applies a config to all probers. It is all or nothing: the targets of all probers are built and new probers
+// are started first. If anything fails the running config is kept.
 func (pm *ProberManager) Configure(cfg *config.Config) error {
+	pm.configureMu.Lock()
+	defer pm.configureMu.Unlock()
+
 	pathsByPPSRate := make(map[uint64][]config.Path)
 	for _, path := range cfg.Paths {
 		pps := *path.PPS
 		pathsByPPSRate[pps] = append(pathsByPPSRate[pps], path)
 	}
 
-	pm.terminateUnneededProbers(pathsByPPSRate)
-
-	for pps, paths := range pathsByPPSRate {
+	probers := make(map[uint64][]*prober.Prober, len(pathsByPPSRate))
+	newProbers := make([]*prober.Prober, 0)
+	configurations := make([]*prober.Configuration, 0)
+	for _, pps := range slices.Sorted(maps.Keys(pathsByPPSRate)) {
+		paths := pathsByPPSRate[pps]
 		targetConfigs := make([]target.TargetConfig, 0)
 		for _, path := range paths {
-			targetConfigs = append(targetConfigs, target.Targets(path, cfg)...)
+			tcs, err := target.Targets(path, cfg)
+			if err != nil {
+				return fmt.Errorf("unable to get targets of path %q: %v", path.Name, err)
+			}
+
+			targetConfigs = append(targetConfigs, tcs...)
 		}
 
-		probers, err := pm.GetProbers(pps)
-		if err != nil {
-			return fmt.Errorf("unable to get prober for pps %d: %v", pps, err)
+		ppsProbers, isNew := pm.getProbers(pps)
+		probers[pps] = ppsProbers
+		if isNew {
+			newProbers = append(newProbers, ppsProbers...)
 		}
 
-		for i, group := range splitTargetConfigs(targetConfigs, len(probers)) {
-			err = probers[i].Configure(group)
+		for i, group := range splitTargetConfigs(targetConfigs, len(ppsProbers)) {
+			c, err := ppsProbers[i].Prepare(group)
 			if err != nil {
 				return fmt.Errorf("failed to configure probers %d/%d: %v", pps, i, err)
 			}
+
+			configurations = append(configurations, c)
 		}
 	}
 
-	return nil
-}
+	for i, p := range newProbers {
+		err := p.Start()
+		if err != nil {
+			for _, started := range newProbers[:i] {
+				started.Stop()
+			}
 
-func (pm *ProberManager) terminateUnneededProbers(neededProbers map[uint64][]config.Path) {
-	probersToStop := make([]uint64, 0)
+			return fmt.Errorf("unable to start prober: %v", err)
+		}
+	}
 
+	// Scrapes hold probersMu while collecting, so they see either the old or the new config of all probers.
+	// Senders of different probers are independent and switch over one after the other.
 	pm.probersMu.Lock()
 	defer pm.probersMu.Unlock()
 
-	for pps := range pm.probers {
-		if _, exists := neededProbers[pps]; !exists {
-			probersToStop = append(probersToStop, pps)
-		}
+	for _, c := range configurations {
+		c.Commit()
 	}
 
-	for _, pps := range probersToStop {
-		for _, p := range pm.probers[pps] {
-			p.Stop()
+	for pps, ppsProbers := range pm.probers {
+		if _, needed := probers[pps]; needed {
+			continue
 		}
 
-		delete(pm.probers, pps)
+		for _, p := range ppsProbers {
+			p.Stop()
+		}
 	}
+
+	pm.probers = probers
+
+	return nil
 }
 
+// splitTargetConfigs distributes the targets over nGroups probers. A target is always put into the same group,
+// so unchanged targets stay on their prober and keep their state across reloads.
 func splitTargetConfigs(targets []target.TargetConfig, nGroups int) [][]target.TargetConfig {
 	ret := make([][]target.TargetConfig, nGroups)
 	for i := range nGroups {
 		ret[i] = make([]target.TargetConfig, 0, len(targets)/nGroups)
 	}
 
-	for i, t := range targets {
-		ret[i%nGroups] = append(ret[i%nGroups], t)
+	for _, t := range targets {
+		i := groupOf(t.GetID(), nGroups)
+		ret[i] = append(ret[i], t)
 	}
 
 	return ret
 }
 
+func groupOf(id target.TargetID, nGroups int) int {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s/%d/%d", id.Path, id.TOS.Name, id.TOS.Value, id.Size)
+
+	return int(h.Sum64() % uint64(nGroups))
+}
+
+// GetCollectors returns the collector of all probers
 func (pm *ProberManager) GetCollectors() []prometheus.Collector {
-	ret := make([]prometheus.Collector, 0)
+	return []prometheus.Collector{pm}
+}
+
+// Describe is a no-op as the probers are unchecked collectors
+func (pm *ProberManager) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect collects the metrics of all probers. The probers are not reconfigured while collecting.
+func (pm *ProberManager) Collect(ch chan<- prometheus.Metric) {
 	pm.probersMu.RLock()
 	defer pm.probersMu.RUnlock()
 
 	for _, probers := range pm.probers {
 		for _, p := range probers {
-			ret = append(ret, p)
+			p.Collect(ch)
 		}
 	}
-
-	return ret
 }
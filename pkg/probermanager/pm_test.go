@@ -0,0 +1,168 @@
+package probermanager
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/bio-routing/matroschka-prober/pkg/prober"
+	"github.com/bio-routing/matroschka-prober/pkg/target"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTargetConfigs(t *testing.T) {
+	targets := make([]target.TargetConfig, 0)
+	for i := range 100 {
+		targets = append(targets, target.TargetConfig{
+			Name: fmt.Sprintf("path%02d", i),
+			TOS:  target.TOS{Name: "BE"},
+		})
+	}
+
+	groupsOf := func(groups [][]target.TargetConfig) map[string]int {
+		ret := make(map[string]int)
+		for i, group := range groups {
+			for _, tc := range group {
+				ret[tc.Name] = i
+			}
+		}
+
+		return ret
+	}
+
+	before := groupsOf(splitTargetConfigs(targets, 8))
+	assert.Len(t, before, 100)
+
+	// Adding and removing targets does not move the others
+	after := groupsOf(splitTargetConfigs(append(targets[10:], target.TargetConfig{
+		Name: "path-new",
+		TOS:  target.TOS{Name: "BE"},
+	}), 8))
+	for name, group := range after {
+		if name == "path-new" {
+			continue
+		}
+
+		assert.Equal(t, before[name], group, name)
+	}
+}
+
+func TestConfigureKeepsRunningConfigOnFailure(t *testing.T) {
+	cfg := &config.Config{
+		Paths: []config.Path{
+			{
+				Name: "path01",
+				Hops: []string{"router01"},
+				// There is no IPv6 prober address to return to
+				ReturnAFI: 6,
+			},
+		},
+		Routers: []config.Router{
+			{
+				Name:        "router01",
+				DstRangeStr: "169.254.0.0/32",
+				SrcRangeStr: "192.0.2.0/32",
+			},
+		},
+	}
+
+	err := cfg.ApplyDefaults()
+	assert.NoError(t, err)
+	err = cfg.ConvertIPAddresses()
+	assert.NoError(t, err)
+
+	pm := New(32768, net.ParseIP("192.0.2.1"), nil, time.Second, 0)
+	running := []*prober.Prober{prober.New(50, 32768, net.ParseIP("192.0.2.1"), nil, time.Second, 0)}
+	pm.probers[50] = running
+
+	err = pm.Configure(cfg)
+	assert.ErrorContains(t, err, "requires an IPv6 address")
+	assert.Equal(t, map[uint64][]*prober.Prober{50: running}, pm.probers)
+}
+
+func TestConfigureKeepsRunningConfigOnLaterGroupFailure(t *testing.T) {
+	newConfig := func(site string, failingPPS uint64) *config.Config {
+		pps := uint64(25)
+		cfg := &config.Config{
+			Paths: []config.Path{
+				{
+					Name:   "path01",
+					Hops:   []string{"router01"},
+					PPS:    &pps,
+					Labels: map[string]string{"site": site},
+				},
+			},
+			Routers: []config.Router{
+				{
+					Name:        "router01",
+					DstRangeStr: "127.0.0.1/32",
+					SrcRangeStr: "127.0.0.2/32",
+				},
+			},
+		}
+
+		if failingPPS != 0 {
+			cfg.Paths = append(cfg.Paths, config.Path{
+				Name: "path02",
+				Hops: []string{"router01"},
+				PPS:  &failingPPS,
+				// There is no IPv6 prober address to return to
+				ReturnAFI: 6,
+			})
+		}
+
+		err := cfg.ApplyDefaults()
+		assert.NoError(t, err)
+		err = cfg.ConvertIPAddresses()
+		assert.NoError(t, err)
+
+		return cfg
+	}
+
+	running := prober.New(25, 32768, nil, nil, time.Second, 0)
+	old := newConfig("fra01", 0)
+	tcs, err := target.Targets(old.Paths[0], old)
+	assert.NoError(t, err)
+	err = running.Configure(tcs)
+	assert.NoError(t, err)
+
+	pm := New(32768, nil, nil, time.Second, 0)
+	pm.probers[25] = []*prober.Prober{running}
+
+	// The group of 25 pps prepares fine, the group of 50 pps fails afterwards
+	err = pm.Configure(newConfig("ber01", 50))
+	assert.ErrorContains(t, err, "requires an IPv6 address")
+	assert.Equal(t, map[uint64][]*prober.Prober{25: {running}}, pm.probers)
+	assert.Equal(t, []string{"fra01"}, collectedSites(t, pm))
+}
+
+// collectedSites returns the site labels of the sent packet counters of all probers
+func collectedSites(t *testing.T, pm *ProberManager) []string {
+	reg := prometheus.NewRegistry()
+	for _, c := range pm.GetCollectors() {
+		reg.MustRegister(c)
+	}
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	ret := make([]string, 0)
+	for _, mf := range mfs {
+		if mf.GetName() != "matroschka_packets_sent_total" {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "site" {
+					ret = append(ret, l.GetValue())
+				}
+			}
+		}
+	}
+
+	return ret
+}
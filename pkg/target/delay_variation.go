@@ -0,0 +1,86 @@
+package target
+
+import (
+	"sync"
+)
+
+const (
+	// delayVariationHistory is the number of sequence numbers the RTT is kept of to find consecutive probes
+	delayVariationHistory = 1024
+)
+
+// delayVariation tracks the interarrival jitter (RFC 3550) and the delay variation of consecutive probes (RFC 3393)
+// as the probes arrive, so the RTTs do not need to be kept
+type delayVariation struct {
+	mu      sync.Mutex
+	arrived bool
+	lastRTT uint64
+	jitter  float64
+	// rtts of the probes by sequence number modulo the history
+	rtts []seqRTT
+}
+
+type seqRTT struct {
+	seq   uint64
+	rtt   uint64
+	valid bool
+}
+
+func newDelayVariation() *delayVariation {
+	return &delayVariation{
+		rtts: make([]seqRTT, delayVariationHistory),
+	}
+}
+
+// add records the arrival of probe seq. It returns the jitter and the delay variations to the consecutive probes that arrived before.
+func (d *delayVariation) add(seq uint64, rtt uint64) (float64, []uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.arrived {
+		d.jitter += (float64(absDiff(rtt, d.lastRTT)) - d.jitter) / 16
+	}
+	d.arrived = true
+	d.lastRTT = rtt
+
+	ipdvs := make([]uint64, 0, 2)
+	if prev, ok := d.rtt(seq - 1); seq > 0 && ok {
+		ipdvs = append(ipdvs, absDiff(rtt, prev))
+	}
+
+	// The next probe overtook this one
+	if next, ok := d.rtt(seq + 1); ok {
+		ipdvs = append(ipdvs, absDiff(next, rtt))
+	}
+
+	d.rtts[seq%delayVariationHistory] = seqRTT{
+		seq:   seq,
+		rtt:   rtt,
+		valid: true,
+	}
+
+	return d.jitter, ipdvs
+}
+
+func (d *delayVariation) rtt(seq uint64) (uint64, bool) {
+	e := d.rtts[seq%delayVariationHistory]
+	if !e.valid || e.seq != seq {
+		return 0, false
+	}
+
+	return e.rtt, true
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+// DelayVariation records the RTT of probe seq. It returns the interarrival jitter (RFC 3550) in nanoseconds and the
+// delay variations (RFC 3393) to the probes before and after seq if they arrived already.
+func (t *Target) DelayVariation(seq uint64, rtt uint64) (float64, []uint64) {
+	return t.delayVariation.add(seq, rtt)
+}
@@ -0,0 +1,76 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayVariation(t *testing.T) {
+	type arrival struct {
+		seq uint64
+		rtt uint64
+	}
+
+	tests := []struct {
+		name           string
+		arrivals       []arrival
+		expectedJitter float64
+		expectedIPDVs  []uint64
+	}{
+		{
+			name: "constant rtts",
+			arrivals: []arrival{
+				{seq: 0, rtt: 100}, {seq: 1, rtt: 100}, {seq: 2, rtt: 100},
+			},
+			expectedJitter: 0,
+			expectedIPDVs:  []uint64{0, 0},
+		},
+		{
+			name: "varying rtts",
+			arrivals: []arrival{
+				{seq: 0, rtt: 100}, {seq: 1, rtt: 116}, {seq: 2, rtt: 100},
+			},
+			expectedJitter: 1 + 15.0/16,
+			expectedIPDVs:  []uint64{16, 16},
+		},
+		{
+			name: "reordered",
+			arrivals: []arrival{
+				{seq: 1, rtt: 120}, {seq: 0, rtt: 100}, {seq: 2, rtt: 90},
+			},
+			expectedJitter: 1.796875,
+			expectedIPDVs:  []uint64{20, 30},
+		},
+		{
+			name: "lost probe",
+			arrivals: []arrival{
+				{seq: 0, rtt: 100}, {seq: 1, rtt: 120}, {seq: 3, rtt: 90}, {seq: 4, rtt: 95},
+			},
+			expectedJitter: 3.1689453125,
+			expectedIPDVs:  []uint64{20, 5},
+		},
+		{
+			name: "sequence numbers wrapping the history",
+			arrivals: []arrival{
+				{seq: 0, rtt: 100}, {seq: delayVariationHistory, rtt: 100}, {seq: 2*delayVariationHistory + 1, rtt: 100},
+			},
+			expectedJitter: 0,
+			expectedIPDVs:  []uint64{},
+		},
+	}
+
+	for _, test := range tests {
+		d := newDelayVariation()
+		jitter := float64(0)
+		ipdvs := make([]uint64, 0)
+		for _, a := range test.arrivals {
+			var got []uint64
+			jitter, got = d.add(a.seq, a.rtt)
+			ipdvs = append(ipdvs, got...)
+		}
+
+		assert.InDelta(t, test.expectedJitter, jitter, 1e-9, test.name)
+		assert.Equal(t, test.expectedIPDVs, ipdvs, test.name)
+	}
+}
@@ -0,0 +1,59 @@
+package target
+
+import (
+	"sync"
+)
+
+const (
+	// duplicateWindow is the number of the latest per target sequence numbers for which duplicates are detected
+	duplicateWindow = 1 << 14
+)
+
+// duplicateDetector detects probes that arrive more than once by their per target sequence numbers.
+// It remembers the arrivals within a window below the highest sequence number that arrived.
+type duplicateDetector struct {
+	mu sync.Mutex
+	// next is the highest sequence number that arrived plus one
+	next uint64
+	// arrived is a bitmap of the sequence numbers in the window
+	arrived []uint64
+}
+
+func newDuplicateDetector() *duplicateDetector {
+	return &duplicateDetector{
+		arrived: make([]uint64, duplicateWindow/64),
+	}
+}
+
+// arrive records the arrival of the probe with sequence number seq. It returns true if the probe arrived before.
+// Probes below the window can not be told apart and are never reported as duplicates.
+func (d *duplicateDetector) arrive(seq uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if seq >= d.next {
+		// The bits of the sequence numbers entering the window still belong to the ones leaving it
+		if seq-d.next >= duplicateWindow {
+			clear(d.arrived)
+		} else {
+			for s := d.next; s <= seq; s++ {
+				d.arrived[s%duplicateWindow/64] &^= 1 << (s % 64)
+			}
+		}
+
+		d.next = seq + 1
+	} else if d.next-seq > duplicateWindow {
+		return false
+	}
+
+	i, bit := seq%duplicateWindow/64, uint64(1)<<(seq%64)
+	duplicate := d.arrived[i]&bit != 0
+	d.arrived[i] |= bit
+
+	return duplicate
+}
+
+// Duplicate records the arrival of the probe with the per target sequence number seq. It returns true if the probe arrived before.
+func (t *Target) Duplicate(seq uint64) bool {
+	return t.dupDetector.arrive(seq)
+}
@@ -0,0 +1,56 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateDetector(t *testing.T) {
+	tests := []struct {
+		name               string
+		arrivals           []uint64
+		expectedDuplicates []bool
+	}{
+		{
+			name:               "no duplicates",
+			arrivals:           []uint64{0, 1, 3, 2},
+			expectedDuplicates: []bool{false, false, false, false},
+		},
+		{
+			name:               "duplicates",
+			arrivals:           []uint64{0, 1, 1, 2, 0},
+			expectedDuplicates: []bool{false, false, true, false, true},
+		},
+		{
+			name:               "sequence number reused by the window",
+			arrivals:           []uint64{1, duplicateWindow + 1},
+			expectedDuplicates: []bool{false, false},
+		},
+		{
+			name:               "window advances by less than its size",
+			arrivals:           []uint64{5, duplicateWindow + 3, duplicateWindow + 5, 5},
+			expectedDuplicates: []bool{false, false, false, false},
+		},
+		{
+			name:               "duplicate at the end of the window",
+			arrivals:           []uint64{5, duplicateWindow + 4, 5},
+			expectedDuplicates: []bool{false, false, true},
+		},
+		{
+			name:               "below the window",
+			arrivals:           []uint64{0, 2 * duplicateWindow, 0},
+			expectedDuplicates: []bool{false, false, false},
+		},
+	}
+
+	for _, test := range tests {
+		d := newDuplicateDetector()
+		duplicates := make([]bool, 0, len(test.arrivals))
+		for _, seq := range test.arrivals {
+			duplicates = append(duplicates, d.arrive(seq))
+		}
+
+		assert.Equal(t, test.expectedDuplicates, duplicates, test.name)
+	}
+}
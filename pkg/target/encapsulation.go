@@ -0,0 +1,269 @@
+package target
+
+import (
+	"fmt"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	greInUDPPort  = 4754
+	mplsInUDPPort = 6635
+	vxlanPort     = 4789
+	genevePort    = 6081
+
+	// Source ports of UDP based encapsulations are taken from the dynamic port range (RFC 7510)
+	udpEntropyPortBase = 49152
+	udpEntropyPorts    = 16384
+)
+
+// encapsulator builds the headers that carry a probe to a hop. The encapsulators of all hops are chained to build a probe.
+type encapsulator interface {
+	headerBuilder
+	// protocol returns the IP protocol of the header carrying the encapsulation
+	protocol(h *hopContext) layers.IPProtocol
+}
+
+// headerBuilder builds the headers of an encapsulation. It can be nested into another encapsulation, e.g. UDP.
+type headerBuilder interface {
+	// headers returns the headers following the IP header towards the hop
+	headers(h *hopContext) ([]gopacket.SerializableLayer, error)
+	// validate checks that the hop has all parameters the encapsulation needs
+	validate(h *config.Hop) error
+}
+
+// hopContext is the state an encapsulator needs to build the headers towards a hop
+type hopContext struct {
+	hop *config.Hop
+	seq uint64
+	tos uint8
+	// ip is the IP header in front of the encapsulation. The outermost one is built by the socket and only used for checksums.
+	ip ipLayer
+	// nextAFI is the address family of the IP header following the encapsulation
+	nextAFI uint8
+}
+
+var encapsulators = map[string]encapsulator{
+	config.EncapsulationGRE:         &greEncapsulator{},
+	config.EncapsulationIPIP:        &ipipEncapsulator{},
+	config.EncapsulationMPLSOverGRE: &greEncapsulator{mpls: true},
+	config.EncapsulationMPLSInIP:    &mplsEncapsulator{},
+	config.EncapsulationGREInUDP: &udpEncapsulator{
+		port:  greInUDPPort,
+		inner: &greEncapsulator{},
+	},
+	config.EncapsulationMPLSInUDP: &udpEncapsulator{
+		port:  mplsInUDPPort,
+		inner: &mplsEncapsulator{},
+	},
+	config.EncapsulationVXLAN: &udpEncapsulator{
+		port:  vxlanPort,
+		inner: &overlayEncapsulator{},
+	},
+	config.EncapsulationGeneve: &udpEncapsulator{
+		port:  genevePort,
+		inner: &overlayEncapsulator{geneve: true},
+	},
+}
+
+// getEncapsulator returns the encapsulator of an encapsulation. Hops without encapsulation use GRE.
+func getEncapsulator(name string) (encapsulator, error) {
+	if name == "" {
+		name = config.EncapsulationGRE
+	}
+
+	e, ok := encapsulators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encapsulation %q", name)
+	}
+
+	return e, nil
+}
+
+// greEncapsulator carries the next IP header or an MPLS label stack in GRE
+type greEncapsulator struct {
+	mpls bool
+}
+
+func (g *greEncapsulator) protocol(h *hopContext) layers.IPProtocol {
+	return layers.IPProtocolGRE
+}
+
+func (g *greEncapsulator) headers(h *hopContext) ([]gopacket.SerializableLayer, error) {
+	if g.mpls {
+		return append([]gopacket.SerializableLayer{greHeader(h.hop.GRE, layers.EthernetTypeMPLSUnicast, h.seq)}, mplsLabels(h.hop.MPLS, h.tos)...), nil
+	}
+
+	return []gopacket.SerializableLayer{greHeader(h.hop.GRE, etherTypeOf(h.nextAFI), h.seq)}, nil
+}
+
+func (g *greEncapsulator) validate(h *config.Hop) error {
+	if g.mpls {
+		return validateMPLS(h)
+	}
+
+	return nil
+}
+
+// greHeader returns a GRE header carrying proto with the optional fields configured in opts
+func greHeader(opts *config.GRE, proto layers.EthernetType, seq uint64) *layers.GRE {
+	gre := &layers.GRE{
+		Protocol: proto,
+	}
+
+	if opts == nil {
+		return gre
+	}
+
+	if opts.Key != nil {
+		gre.KeyPresent = true
+		gre.Key = *opts.Key
+	}
+
+	gre.ChecksumPresent = opts.Checksum
+	if opts.Sequence {
+		gre.SeqPresent = true
+		gre.Seq = uint32(seq)
+	}
+
+	return gre
+}
+
+// ipipEncapsulator carries the next IP header directly in IP
+type ipipEncapsulator struct{}
+
+func (i *ipipEncapsulator) protocol(h *hopContext) layers.IPProtocol {
+	if h.nextAFI == 4 {
+		return layers.IPProtocolIPv4
+	}
+
+	return layers.IPProtocolIPv6
+}
+
+func (i *ipipEncapsulator) headers(h *hopContext) ([]gopacket.SerializableLayer, error) {
+	return nil, nil
+}
+
+func (i *ipipEncapsulator) validate(h *config.Hop) error {
+	return nil
+}
+
+// mplsEncapsulator carries an MPLS label stack directly in IP (RFC 4023)
+type mplsEncapsulator struct{}
+
+func (m *mplsEncapsulator) protocol(h *hopContext) layers.IPProtocol {
+	return layers.IPProtocolMPLSInIP
+}
+
+func (m *mplsEncapsulator) headers(h *hopContext) ([]gopacket.SerializableLayer, error) {
+	return mplsLabels(h.hop.MPLS, h.tos), nil
+}
+
+func (m *mplsEncapsulator) validate(h *config.Hop) error {
+	return validateMPLS(h)
+}
+
+// mplsLabels returns the label stack of m. The traffic class is taken from the precedence bits of the TOS.
+func mplsLabels(m *config.MPLS, tos uint8) []gopacket.SerializableLayer {
+	ret := make([]gopacket.SerializableLayer, 0, len(m.Labels))
+	for i, label := range m.Labels {
+		ret = append(ret, &layers.MPLS{
+			Label:        label,
+			TrafficClass: tos >> 5,
+			StackBottom:  i == len(m.Labels)-1,
+			TTL:          *m.TTL,
+		})
+	}
+
+	return ret
+}
+
+func validateMPLS(h *config.Hop) error {
+	if h.MPLS == nil || len(h.MPLS.Labels) == 0 {
+		return fmt.Errorf("router %q has no MPLS label stack", h.Name)
+	}
+
+	return nil
+}
+
+// udpEncapsulator carries the headers of the inner encapsulator in UDP. The source port is rotated with the
+// sequence number like the source address to provide entropy for ECMP hashing.
+type udpEncapsulator struct {
+	port  layers.UDPPort
+	inner headerBuilder
+}
+
+func (u *udpEncapsulator) protocol(h *hopContext) layers.IPProtocol {
+	return layers.IPProtocolUDP
+}
+
+func (u *udpEncapsulator) headers(h *hopContext) ([]gopacket.SerializableLayer, error) {
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(udpEntropyPortBase + h.seq%udpEntropyPorts),
+		DstPort: u.port,
+	}
+
+	err := udp.SetNetworkLayerForChecksum(h.ip)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't set the network layer for checksum: %w", err)
+	}
+
+	inner, err := u.inner.headers(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]gopacket.SerializableLayer{udp}, inner...), nil
+}
+
+func (u *udpEncapsulator) validate(h *config.Hop) error {
+	return u.inner.validate(h)
+}
+
+// overlayEncapsulator carries the next IP header in an Ethernet frame of a VXLAN or Geneve virtual network
+type overlayEncapsulator struct {
+	geneve bool
+}
+
+func (o *overlayEncapsulator) headers(h *hopContext) ([]gopacket.SerializableLayer, error) {
+	ov := h.hop.Overlay
+
+	var header gopacket.SerializableLayer = &layers.VXLAN{
+		ValidIDFlag: true,
+		VNI:         ov.VNI,
+	}
+
+	if o.geneve {
+		header = &geneveHeader{
+			VNI: ov.VNI,
+		}
+	}
+
+	eth := &ethernetHeader{
+		Ethernet: layers.Ethernet{
+			SrcMAC:       ov.SrcMAC,
+			DstMAC:       ov.DstMAC,
+			EthernetType: etherTypeOf(h.nextAFI),
+		},
+	}
+
+	return []gopacket.SerializableLayer{header, eth}, nil
+}
+
+func (o *overlayEncapsulator) validate(h *config.Hop) error {
+	if h.Overlay == nil || config.ValidateVNI(h.Overlay.VNI) != nil || len(h.Overlay.SrcMAC) != 6 || len(h.Overlay.DstMAC) != 6 {
+		return fmt.Errorf("router %q has no valid overlay", h.Name)
+	}
+
+	return nil
+}
+
+func etherTypeOf(afi uint8) layers.EthernetType {
+	if afi == 4 {
+		return layers.EthernetTypeIPv4
+	}
+
+	return layers.EthernetTypeIPv6
+}
@@ -0,0 +1,79 @@
+package target
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// rttHistogram counts RTTs into buckets. It is cumulative over the lifetime of the target so it can be aggregated with rate().
+type rttHistogram struct {
+	// upperBounds of the buckets in seconds
+	upperBounds []float64
+	// counts of the buckets, not cumulative. The last one counts the RTTs above all upper bounds.
+	counts []uint64
+	sumNS  uint64
+}
+
+func newRTTHistogram(upperBounds []float64) *rttHistogram {
+	return &rttHistogram{
+		upperBounds: upperBounds,
+		counts:      make([]uint64, len(upperBounds)+1),
+	}
+}
+
+func (h *rttHistogram) observe(rttNS uint64) {
+	i := sort.SearchFloat64s(h.upperBounds, float64(rttNS)/float64(time.Second))
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.sumNS, rttNS)
+}
+
+// snapshot returns the count, the sum in seconds and the cumulative counts by upper bound
+func (h *rttHistogram) snapshot() (uint64, float64, map[float64]uint64) {
+	buckets := make(map[float64]uint64, len(h.upperBounds))
+	cumulative := uint64(0)
+	for i, ub := range h.upperBounds {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		buckets[ub] = cumulative
+	}
+
+	count := cumulative + atomic.LoadUint64(&h.counts[len(h.upperBounds)])
+	sum := float64(atomic.LoadUint64(&h.sumNS)) / float64(time.Second)
+
+	return count, sum, buckets
+}
+
+func validateHistogramBuckets(upperBounds []float64) error {
+	for i := range upperBounds {
+		if upperBounds[i] <= 0 {
+			return fmt.Errorf("bucket %v is not positive", upperBounds[i])
+		}
+
+		if i > 0 && upperBounds[i] <= upperBounds[i-1] {
+			return fmt.Errorf("buckets must be in increasing order, %v follows %v", upperBounds[i], upperBounds[i-1])
+		}
+	}
+
+	return nil
+}
+
+// ObserveRTT adds an RTT in nanoseconds to the histogram of the target
+func (t *Target) ObserveRTT(rtt uint64) {
+	if t.histogram == nil {
+		return
+	}
+
+	t.histogram.observe(rtt)
+}
+
+// RTTHistogram returns the count, the sum in seconds and the cumulative bucket counts of the RTTs.
+// The bool is false if the target has no histogram.
+func (t *Target) RTTHistogram() (uint64, float64, map[float64]uint64, bool) {
+	if t.histogram == nil {
+		return 0, 0, nil, false
+	}
+
+	count, sum, buckets := t.histogram.snapshot()
+	return count, sum, buckets, true
+}
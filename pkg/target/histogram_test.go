@@ -0,0 +1,54 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTTHistogram(t *testing.T) {
+	tests := []struct {
+		name            string
+		upperBounds     []float64
+		rtts            []uint64
+		expectedCount   uint64
+		expectedSum     float64
+		expectedBuckets map[float64]uint64
+	}{
+		{
+			name:            "no rtts",
+			upperBounds:     []float64{0.001, 0.01},
+			expectedBuckets: map[float64]uint64{0.001: 0, 0.01: 0},
+		},
+		{
+			name:          "rtts on, between and above bounds",
+			upperBounds:   []float64{0.001, 0.01},
+			rtts:          []uint64{500000, 1000000, 5000000, 20000000},
+			expectedCount: 4,
+			expectedSum:   0.0265,
+			expectedBuckets: map[float64]uint64{
+				0.001: 2,
+				0.01:  3,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		h := newRTTHistogram(test.upperBounds)
+		for _, rtt := range test.rtts {
+			h.observe(rtt)
+		}
+
+		count, sum, buckets := h.snapshot()
+		assert.Equal(t, test.expectedCount, count, test.name)
+		assert.InDelta(t, test.expectedSum, sum, 1e-9, test.name)
+		assert.Equal(t, test.expectedBuckets, buckets, test.name)
+	}
+}
+
+func TestValidateHistogramBuckets(t *testing.T) {
+	assert.NoError(t, validateHistogramBuckets(nil))
+	assert.NoError(t, validateHistogramBuckets([]float64{0.001, 0.01}))
+	assert.Error(t, validateHistogramBuckets([]float64{0, 0.01}))
+	assert.Error(t, validateHistogramBuckets([]float64{0.01, 0.001}))
+}
@@ -0,0 +1,100 @@
+package target
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// lossBurstBuckets are the upper bounds of the loss burst length histogram in packets
+var lossBurstBuckets = []float64{1, 2, 3, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// lossBurstTracker tracks runs of consecutively lost probes. Probes are fed in order of their per target sequence number
+// once they are either received or timed out.
+type lossBurstTracker struct {
+	mu sync.Mutex
+	// window is the start of the measurement window of the last expired probe
+	window int64
+	// run is the length of the current burst
+	run uint64
+	// windowLongest is the longest burst seen in the current window, lastWindowLongest the one of the previous window
+	windowLongest     uint64
+	lastWindowLongest uint64
+	// counts of the buckets, not cumulative. The last one counts the bursts longer than all buckets.
+	counts []uint64
+	count  uint64
+	sum    uint64
+}
+
+func newLossBurstTracker() *lossBurstTracker {
+	return &lossBurstTracker{
+		counts: make([]uint64, len(lossBurstBuckets)+1),
+	}
+}
+
+// expired records the outcome of a probe sent in window
+func (l *lossBurstTracker) expired(window int64, lost bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if window > l.window {
+		l.lastWindowLongest = l.windowLongest
+		// A burst spanning the windows counts for both
+		l.windowLongest = l.run
+		l.window = window
+	}
+
+	if lost {
+		l.run++
+		l.windowLongest = max(l.windowLongest, l.run)
+		return
+	}
+
+	if l.run == 0 {
+		return
+	}
+
+	l.counts[sort.SearchFloat64s(lossBurstBuckets, float64(l.run))]++
+	l.count++
+	l.sum += l.run
+	l.run = 0
+}
+
+// snapshot returns the number of bursts, the number of packets lost in them and the cumulative counts by upper bound
+func (l *lossBurstTracker) snapshot() (uint64, float64, map[float64]uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := make(map[float64]uint64, len(lossBurstBuckets))
+	cumulative := uint64(0)
+	for i, ub := range lossBurstBuckets {
+		cumulative += l.counts[i]
+		buckets[ub] = cumulative
+	}
+
+	return l.count, float64(l.sum), buckets
+}
+
+func (l *lossBurstTracker) longestInLastWindow() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lastWindowLongest
+}
+
+// ProbeExpired records whether a probe sent at sentTsNS got lost once it can no longer arrive.
+// Probes must be passed in order of their per target sequence number.
+func (t *Target) ProbeExpired(sentTsNS int64, lost bool) {
+	measurementLengthNS := int64(t.cfg.MeasurementLengthMS) * int64(time.Millisecond)
+	t.lossBursts.expired(sentTsNS-sentTsNS%measurementLengthNS, lost)
+}
+
+// LossBursts returns the number of loss bursts, the number of packets lost in them and the cumulative bucket counts of their lengths
+func (t *Target) LossBursts() (uint64, float64, map[float64]uint64) {
+	return t.lossBursts.snapshot()
+}
+
+// LongestLossBurst returns the longest burst of consecutively lost probes in the last measurement window whose probes all expired
+func (t *Target) LongestLossBurst() uint64 {
+	return t.lossBursts.longestInLastWindow()
+}
@@ -0,0 +1,77 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLossBurstTracker(t *testing.T) {
+	type probe struct {
+		window int64
+		lost   bool
+	}
+
+	tests := []struct {
+		name            string
+		probes          []probe
+		expectedCount   uint64
+		expectedSum     float64
+		expectedBuckets map[float64]uint64
+		expectedLongest uint64
+	}{
+		{
+			name: "no loss",
+			probes: []probe{
+				{window: 1}, {window: 1}, {window: 2},
+			},
+			expectedBuckets: map[float64]uint64{1: 0, 2: 0, 3: 0, 5: 0, 10: 0, 20: 0, 50: 0, 100: 0, 200: 0, 500: 0, 1000: 0},
+		},
+		{
+			name: "single drops and a burst",
+			probes: []probe{
+				{window: 1, lost: true}, {window: 1}, {window: 1, lost: true}, {window: 1, lost: true}, {window: 1, lost: true}, {window: 1},
+				{window: 2, lost: true}, {window: 2},
+				{window: 3},
+			},
+			expectedCount:   3,
+			expectedSum:     5,
+			expectedBuckets: map[float64]uint64{1: 2, 2: 2, 3: 3, 5: 3, 10: 3, 20: 3, 50: 3, 100: 3, 200: 3, 500: 3, 1000: 3},
+			expectedLongest: 1,
+		},
+		{
+			name: "burst spanning windows",
+			probes: []probe{
+				{window: 1}, {window: 1, lost: true}, {window: 1, lost: true},
+				{window: 2, lost: true}, {window: 2},
+				{window: 3},
+			},
+			expectedCount:   1,
+			expectedSum:     3,
+			expectedBuckets: map[float64]uint64{1: 0, 2: 0, 3: 1, 5: 1, 10: 1, 20: 1, 50: 1, 100: 1, 200: 1, 500: 1, 1000: 1},
+			expectedLongest: 3,
+		},
+		{
+			name: "open burst",
+			probes: []probe{
+				{window: 1, lost: true}, {window: 1, lost: true},
+				{window: 2, lost: true},
+			},
+			expectedBuckets: map[float64]uint64{1: 0, 2: 0, 3: 0, 5: 0, 10: 0, 20: 0, 50: 0, 100: 0, 200: 0, 500: 0, 1000: 0},
+			expectedLongest: 2,
+		},
+	}
+
+	for _, test := range tests {
+		l := newLossBurstTracker()
+		for _, p := range test.probes {
+			l.expired(p.window, p.lost)
+		}
+
+		count, sum, buckets := l.snapshot()
+		assert.Equal(t, test.expectedCount, count, test.name)
+		assert.Equal(t, test.expectedSum, sum, test.name)
+		assert.Equal(t, test.expectedBuckets, buckets, test.name)
+		assert.Equal(t, test.expectedLongest, l.longestInLastWindow(), test.name)
+	}
+}
@@ -0,0 +1,297 @@
+package target
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+)
+
+const (
+	// minMTU4 is the smallest MTU every IPv4 link must support (RFC 791)
+	minMTU4 = 68
+	// minMTU6 is the smallest MTU every IPv6 link must support (RFC 8200)
+	minMTU6 = 1280
+)
+
+// mtuSweeper searches the largest packet that makes it through a path. All sizes are payload sizes.
+type mtuSweeper struct {
+	mode     string
+	overhead uint64
+	min      uint64
+	max      uint64
+	l        sync.Mutex
+
+	// binary mode: lo is the largest size known to work, hi the largest size not known to fail
+	lo uint64
+	hi uint64
+
+	// stepped mode
+	sizes   []uint64
+	next    int
+	results map[uint64]bool
+
+	mtu uint64
+}
+
+func newMTUSweeper(cfg *config.MTUSweep, overhead uint64, minPayloadSize uint64) (*mtuSweeper, error) {
+	s := &mtuSweeper{
+		mode:     cfg.Mode,
+		overhead: overhead,
+		min:      minPayloadSize,
+	}
+
+	if cfg.MinBytes > overhead+minPayloadSize {
+		s.min = cfg.MinBytes - overhead
+	}
+
+	if *cfg.MaxBytes < overhead+s.min {
+		return nil, fmt.Errorf("max_bytes %d is smaller than the smallest possible probe of %d bytes", *cfg.MaxBytes, overhead+s.min)
+	}
+	s.max = *cfg.MaxBytes - overhead
+
+	switch s.mode {
+	case config.MTUSweepModeBinary:
+		s.reset()
+	case config.MTUSweepModeStepped:
+		if *cfg.StepBytes == 0 {
+			return nil, fmt.Errorf("step_bytes must not be 0")
+		}
+
+		for size := s.min; size < s.max; size += *cfg.StepBytes {
+			s.sizes = append(s.sizes, size)
+		}
+		s.sizes = append(s.sizes, s.max)
+		s.results = make(map[uint64]bool, len(s.sizes))
+	default:
+		return nil, fmt.Errorf("unknown MTU sweep mode %q", s.mode)
+	}
+
+	return s, nil
+}
+
+func (s *mtuSweeper) reset() {
+	s.lo = s.min - 1
+	s.hi = s.max
+}
+
+// nextSize returns the payload size of the next probe
+func (s *mtuSweeper) nextSize() uint64 {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.mode == config.MTUSweepModeStepped {
+		size := s.sizes[s.next]
+		s.next = (s.next + 1) % len(s.sizes)
+		return size
+	}
+
+	return s.lo + (s.hi-s.lo+1)/2
+}
+
+func (s *mtuSweeper) received(size uint64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.mode == config.MTUSweepModeStepped {
+		s.results[size] = true
+		return
+	}
+
+	if size > s.lo {
+		s.lo = size
+	}
+
+	s.settle()
+}
+
+func (s *mtuSweeper) lost(size uint64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.mode == config.MTUSweepModeStepped {
+		s.results[size] = false
+		return
+	}
+
+	// Sizes known to work are lost by chance
+	if size <= s.lo {
+		return
+	}
+
+	if size-1 < s.hi {
+		s.hi = size - 1
+	}
+
+	s.settle()
+}
+
+// limit marks all sizes larger than maxSize as failed
+func (s *mtuSweeper) limit(maxSize uint64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.mode == config.MTUSweepModeStepped {
+		for _, size := range s.sizes {
+			if size > maxSize {
+				s.results[size] = false
+			}
+		}
+
+		return
+	}
+
+	if maxSize < s.hi {
+		s.hi = max(maxSize, s.lo)
+	}
+
+	s.settle()
+}
+
+// settle publishes the result of a finished binary search and starts over
+func (s *mtuSweeper) settle() {
+	if s.lo < s.hi {
+		return
+	}
+
+	s.mtu = 0
+	if s.lo >= s.min {
+		s.mtu = s.lo + s.overhead
+	}
+
+	s.reset()
+}
+
+// pathMTU returns the largest packet size in bytes that made it back, 0 if none did
+func (s *mtuSweeper) pathMTU() uint64 {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.mode == config.MTUSweepModeBinary {
+		return s.mtu
+	}
+
+	ret := uint64(0)
+	for _, size := range s.sizes {
+		if !s.results[size] {
+			break
+		}
+
+		ret = size + s.overhead
+	}
+
+	return ret
+}
+
+func (t *Target) newMTUSweeper() (*mtuSweeper, error) {
+	overhead, err := t.overhead()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine overhead: %w", err)
+	}
+
+	pr := Probe{}
+	return newMTUSweeper(t.cfg.MTUSweep, overhead, uint64(len(pr.marshal())))
+}
+
+// PayloadSize returns the payload size of the next probe
+func (t *Target) PayloadSize() uint64 {
+	if t.sweeper == nil {
+		return t.cfg.PayloadSizeBytes
+	}
+
+	return t.sweeper.nextSize()
+}
+
+// ProbeReceived reports a probe of payload size size that made it back
+func (t *Target) ProbeReceived(size uint64) {
+	if t.sweeper == nil {
+		return
+	}
+
+	t.sweeper.received(size)
+}
+
+// ProbeLost reports a probe of payload size size that did not make it back
+func (t *Target) ProbeLost(size uint64) {
+	if t.sweeper == nil {
+		return
+	}
+
+	t.sweeper.lost(size)
+}
+
+// MTUHint takes the MTU reported by an ICMP fragmentation needed or packet too big message
+// for a packet towards dst and limits the MTU sweep accordingly. Hints below the minimum MTU, e.g. the MTU 0 sent by
+// routers predating RFC 1191, are ignored and the sweep relies on its probes alone.
+func (t *Target) MTUHint(dst net.IP, mtu uint64) {
+	if t.sweeper == nil {
+		return
+	}
+
+	if mtu < minMTU(dst) {
+		return
+	}
+
+	stage := t.stageOf(dst)
+	if stage < 0 {
+		return
+	}
+
+	overheads, err := t.stageOverheads()
+	if err != nil {
+		return
+	}
+
+	if mtu < overheads[stage] {
+		t.sweeper.limit(0)
+		return
+	}
+
+	t.sweeper.limit(mtu - overheads[stage])
+}
+
+func minMTU(dst net.IP) uint64 {
+	if dst.To4() != nil {
+		return minMTU4
+	}
+
+	return minMTU6
+}
+
+// stageOf returns the stage of a packet sent towards dst, -1 if dst is not part of the path
+func (t *Target) stageOf(dst net.IP) int {
+	stage := -1
+	for i, h := range t.cfg.Hops {
+		for _, addr := range h.DstRange {
+			if addr.Equal(dst) {
+				stage = i
+				break
+			}
+		}
+
+		if stage >= 0 {
+			break
+		}
+	}
+
+	if stage < 0 && t.localAddr.Equal(dst) {
+		stage = len(t.cfg.Hops)
+	}
+
+	// Segment routed probes keep their headers along the whole path
+	if t.srv6() && stage >= 0 {
+		return 0
+	}
+
+	return stage
+}
+
+// PathMTU returns the largest packet in bytes that made it back. The bool is false if the target does not sweep the MTU.
+func (t *Target) PathMTU() (uint64, bool) {
+	if t.sweeper == nil {
+		return 0, false
+	}
+
+	return t.sweeper.pathMTU(), true
+}
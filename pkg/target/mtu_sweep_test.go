@@ -0,0 +1,165 @@
+package target
+
+import (
+	"net"
+	"testing"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMTUSweeper(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		minBytes  uint64
+		maxBytes  uint64
+		stepBytes uint64
+		pathMTU   uint64
+		hint      uint64
+		probes    int
+		expected  uint64
+	}{
+		{
+			name:      "binary search",
+			mode:      config.MTUSweepModeBinary,
+			maxBytes:  9216,
+			stepBytes: 64,
+			pathMTU:   1476,
+			probes:    100,
+			expected:  1476,
+		},
+		{
+			name:      "binary search with nothing making it back",
+			mode:      config.MTUSweepModeBinary,
+			maxBytes:  9216,
+			stepBytes: 64,
+			pathMTU:   40,
+			probes:    100,
+			expected:  0,
+		},
+		{
+			name:      "binary search with ICMP hint",
+			mode:      config.MTUSweepModeBinary,
+			maxBytes:  9216,
+			stepBytes: 64,
+			pathMTU:   9000,
+			hint:      1400,
+			probes:    20,
+			expected:  1400,
+		},
+		{
+			name:      "stepped",
+			mode:      config.MTUSweepModeStepped,
+			minBytes:  1000,
+			maxBytes:  1500,
+			stepBytes: 100,
+			pathMTU:   1476,
+			probes:    100,
+			expected:  1400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overhead := uint64(76)
+			s, err := newMTUSweeper(&config.MTUSweep{
+				Mode:      tt.mode,
+				MinBytes:  tt.minBytes,
+				MaxBytes:  &tt.maxBytes,
+				StepBytes: &tt.stepBytes,
+			}, overhead, 16)
+			if err != nil {
+				t.Fatalf("unable to create sweeper: %v", err)
+			}
+
+			if tt.hint != 0 {
+				s.limit(tt.hint - overhead)
+			}
+
+			for range tt.probes {
+				size := s.nextSize()
+				if size+overhead <= tt.pathMTU {
+					s.received(size)
+					continue
+				}
+
+				s.lost(size)
+			}
+
+			assert.Equal(t, tt.expected, s.pathMTU(), tt.name)
+		})
+	}
+}
+
+func TestTargetMTUHint(t *testing.T) {
+	tests := []struct {
+		name     string
+		mtu      uint64
+		expected uint64
+	}{
+		{
+			name:     "hint limits the sweep",
+			mtu:      1400,
+			expected: 1400,
+		},
+		{
+			name:     "legacy hint without MTU is ignored",
+			mtu:      0,
+			expected: 9000,
+		},
+		{
+			name:     "hint below the minimum MTU is ignored",
+			mtu:      60,
+			expected: 9000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxBytes := uint64(9216)
+			stepBytes := uint64(64)
+			ta, err := NewTarget(TargetConfig{
+				Name: "test-target",
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				MTUSweep: &config.MTUSweep{
+					Mode:      config.MTUSweepModeBinary,
+					MaxBytes:  &maxBytes,
+					StepBytes: &stepBytes,
+				},
+			}, net.ParseIP("128.0.0.1"))
+			if err != nil {
+				t.Fatalf("unable to create target: %v", err)
+			}
+
+			overhead, err := ta.overhead()
+			if err != nil {
+				t.Fatalf("unable to determine overhead: %v", err)
+			}
+
+			ta.MTUHint(net.ParseIP("169.254.0.0"), tt.mtu)
+
+			// The path carries 9000 bytes, so a hint only bounds the result of the first search if it is honored
+			for range 14 {
+				size := ta.PayloadSize()
+				if size+overhead <= 9000 {
+					ta.ProbeReceived(size)
+					continue
+				}
+
+				ta.ProbeLost(size)
+			}
+
+			mtu, _ := ta.PathMTU()
+			assert.Equal(t, tt.expected, mtu, tt.name)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package target
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	geneveHeaderLen   = 8
+	ethernetHeaderLen = 14
+	etherTypeTEB      = 0x6558
+)
+
+// geneveHeader is a Geneve header (RFC 8926) without options carrying an Ethernet frame. gopacket can only decode Geneve.
+type geneveHeader struct {
+	VNI uint32
+}
+
+func (g *geneveHeader) LayerType() gopacket.LayerType {
+	return layers.LayerTypeGeneve
+}
+
+func (g *geneveHeader) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	err := config.ValidateVNI(g.VNI)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := b.PrependBytes(geneveHeaderLen)
+	if err != nil {
+		return err
+	}
+
+	bytes[0] = 0 // version and options length
+	bytes[1] = 0 // flags
+	binary.BigEndian.PutUint16(bytes[2:4], etherTypeTEB)
+	binary.BigEndian.PutUint32(bytes[4:8], g.VNI<<8) // the lowest byte is reserved
+
+	return nil
+}
+
+// ethernetHeader is the inner Ethernet header of an overlay encapsulation. Unlike layers.Ethernet it does not pad
+// the frame to the minimum Ethernet size as the frame is not sent on a wire and the padding would be part of the overhead.
+type ethernetHeader struct {
+	layers.Ethernet
+}
+
+func (e *ethernetHeader) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(e.DstMAC) != 6 || len(e.SrcMAC) != 6 {
+		return fmt.Errorf("invalid MAC addresses %v -> %v", e.SrcMAC, e.DstMAC)
+	}
+
+	bytes, err := b.PrependBytes(ethernetHeaderLen)
+	if err != nil {
+		return err
+	}
+
+	copy(bytes, e.DstMAC)
+	copy(bytes[6:], e.SrcMAC)
+	binary.BigEndian.PutUint16(bytes[12:], uint16(e.EthernetType))
+
+	return nil
+}
@@ -4,59 +4,62 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/bio-routing/matroschka-prober/pkg/config"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 )
 
 const (
 	ttl = 64
+
+	// maxPacketSize is the largest packet the IP length fields allow
+	maxPacketSize = 65535
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
 )
 
-var (
-	ipv4inGRE = &layers.GRE{
-		Protocol: layers.EthernetTypeIPv4,
+// getSrcAddrHop returns the source address of the header towards hop. It is taken from the src_range of the
+// previous hop, or from the src_range of the hop itself if the previous hop belongs to another address family.
+func (t *Target) getSrcAddrHop(hop int, seq uint64) net.IP {
+	r := t.cfg.Hops[hop-1].SrcRange
+	if hop < len(t.cfg.Hops) && afiOf(r[0]) != t.hopAFI(hop) {
+		r = t.cfg.Hops[hop].SrcRange
 	}
-	ipv6inGRE = &layers.GRE{
-		Protocol: layers.EthernetTypeIPv6,
+
+	return r[seq%uint64(len(r))]
+}
+
+func (t *Target) getReturnSrcAddr(seq uint64) net.IP {
+	if len(t.cfg.ReturnSrcAddrs) == 0 {
+		return t.getSrcAddrHop(len(t.cfg.Hops), seq)
 	}
-)
 
-func (t *Target) getSrcAddrHop(hop int, seq uint64) net.IP {
-	return t.cfg.Hops[hop-1].SrcRange[seq%uint64(len(t.cfg.Hops[hop-1].SrcRange))]
+	return t.cfg.ReturnSrcAddrs[seq%uint64(len(t.cfg.ReturnSrcAddrs))]
 }
 
 func (t *Target) getDstAddr(hop int, seq uint64) net.IP {
 	return t.cfg.Hops[hop].DstRange[seq%uint64(len(t.cfg.Hops[hop].DstRange))]
 }
 
-func (t *Target) CraftPacket(pr Probe, udpPort uint16) ([]byte, error) {
-	probeSer := pr.marshal()
-
-	buf := gopacket.NewSerializeBuffer()
-	opts := gopacket.SerializeOptions{
-		FixLengths:       true,
-		ComputeChecksums: true,
+// CraftPacket crafts a probe packet padded to payloadSize. The returned packet lacks the outermost IP header which is added by the socket.
+func (t *Target) CraftPacket(pr Probe, udpPort uint16, payloadSize uint64) ([]byte, error) {
+	stages, err := t.craftStages(pr.SequenceNumber, udpPort)
+	if err != nil {
+		return nil, err
 	}
 
 	l := make([]gopacket.SerializableLayer, 0, 10)
-
-	var err error
-	ipProtocolVersion := t.firstHopAFI()
-	if ipProtocolVersion == 4 {
-		l, err = t.craftIPV4Packet(pr.SequenceNumber, l, udpPort)
-		if err != nil {
-			return nil, fmt.Errorf("failed to craft IPv4 packet: %w", err)
-		}
+	for _, stage := range stages {
+		l = append(l, stage...)
 	}
 
-	if ipProtocolVersion == 6 {
-		l, err = t.craftIPV6Packet(pr.SequenceNumber, l, udpPort)
-		if err != nil {
-			return nil, fmt.Errorf("failed to craft IPv6 packet: %w", err)
-		}
-	}
+	l = append(l, gopacket.Payload(t.payload(pr, payloadSize)))
 
-	l = append(l, gopacket.Payload(probeSer[:]))
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
 
 	err = gopacket.SerializeLayers(buf, opts, l...)
 	if err != nil {
@@ -66,37 +69,38 @@ func (t *Target) CraftPacket(pr Probe, udpPort uint16) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (t *Target) craftIPV4Packet(sequenceNumber uint64, l []gopacket.SerializableLayer, udpPort uint16) ([]gopacket.SerializableLayer, error) {
-	l = append(l, ipv4inGRE)
+// craftStages returns the layers of a probe packet grouped by stage. Stage 0 follows the outermost IP header,
+// stage i (0 < i < len(hops)) carries the packet to hop i and the last stage is the returning UDP packet.
+func (t *Target) craftStages(sequenceNumber uint64, udpPort uint16) ([][]gopacket.SerializableLayer, error) {
+	if t.srv6() {
+		return t.craftSRv6Stages(sequenceNumber, udpPort)
+	}
 
+	stages := make([][]gopacket.SerializableLayer, 0, len(t.cfg.Hops)+1)
 	for i := range t.cfg.Hops {
-		if i == 0 {
-			continue
+		stage := make([]gopacket.SerializableLayer, 0, 3)
+
+		// The outermost header is built by the socket. It is still needed for UDP checksums.
+		src := t.cfg.GetSrcAddr(sequenceNumber)
+		if i > 0 {
+			src = t.getSrcAddrHop(i, sequenceNumber)
 		}
 
-		l = append(l, &layers.IPv4{
-			SrcIP:    t.getSrcAddrHop(i, sequenceNumber),
-			DstIP:    t.getDstAddr(i, sequenceNumber),
-			Version:  4,
-			Protocol: layers.IPProtocolGRE,
-			TOS:      t.cfg.TOS.Value,
-			TTL:      ttl,
-		})
+		ip := t.ipHeader(t.hopAFI(i), src, t.getDstAddr(i, sequenceNumber), t.hopProtocol(i))
+		if i > 0 {
+			stage = append(stage, ip)
+		}
 
-		l = append(l, ipv4inGRE)
-	}
+		encap, err := t.hopEncapsulation(i, sequenceNumber, ip)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encapsulate hop %d: %w", i, err)
+		}
 
-	// Create final UDP packet that will return
-	ip := &layers.IPv4{
-		SrcIP:    t.getSrcAddrHop(len(t.cfg.Hops), sequenceNumber),
-		DstIP:    t.localAddr,
-		Version:  4,
-		Protocol: layers.IPProtocolUDP,
-		TOS:      t.cfg.TOS.Value,
-		TTL:      ttl,
+		stages = append(stages, append(stage, encap...))
 	}
-	l = append(l, ip)
 
+	// Create final UDP packet that will return
+	ip := t.ipHeader(t.cfg.ReturnAFI, t.getReturnSrcAddr(sequenceNumber), t.localAddr, layers.IPProtocolUDP)
 	udp := &layers.UDP{
 		SrcPort: layers.UDPPort(udpPort),
 		DstPort: layers.UDPPort(udpPort),
@@ -106,60 +110,224 @@ func (t *Target) craftIPV4Packet(sequenceNumber uint64, l []gopacket.Serializabl
 	if err != nil {
 		return nil, fmt.Errorf("couldn't set the network layer for checksum: %w", err)
 	}
-	l = append(l, udp)
+	stages = append(stages, []gopacket.SerializableLayer{ip, udp})
 
-	return l, nil
+	return stages, nil
 }
 
-func (t *Target) craftIPV6Packet(sequenceNumber uint64, l []gopacket.SerializableLayer, udpPort uint16) ([]gopacket.SerializableLayer, error) {
-	l = append(l, ipv6inGRE)
+// FirstHopProtocol returns the IP protocol of the outermost header
+func (t *Target) FirstHopProtocol() layers.IPProtocol {
+	if t.srv6() {
+		return layers.IPProtocolIPv6Routing
+	}
 
-	for i := range t.cfg.Hops {
-		if i == 0 {
-			continue
+	return t.hopProtocol(0)
+}
+
+// hopProtocol returns the IP protocol of the header towards hop
+func (t *Target) hopProtocol(hop int) layers.IPProtocol {
+	return t.encapsulators[hop].protocol(t.hopContext(hop, 0, nil))
+}
+
+// hopEncapsulation returns the layers following the IP header ip towards hop
+func (t *Target) hopEncapsulation(hop int, seq uint64, ip ipLayer) ([]gopacket.SerializableLayer, error) {
+	return t.encapsulators[hop].headers(t.hopContext(hop, seq, ip))
+}
+
+func (t *Target) hopContext(hop int, seq uint64, ip ipLayer) *hopContext {
+	return &hopContext{
+		hop:     &t.cfg.Hops[hop],
+		seq:     seq,
+		tos:     t.cfg.TOS.Value,
+		ip:      ip,
+		nextAFI: t.nextAFI(hop),
+	}
+}
+
+// encapsulationOf returns the encapsulation of the packet sent towards hop. The encapsulation of the path takes precedence.
+func (tc *TargetConfig) encapsulationOf(hop int) string {
+	if tc.Encapsulation != "" {
+		return tc.Encapsulation
+	}
+
+	return tc.Hops[hop].Encapsulation
+}
+
+// encapsulators returns the encapsulator of every hop. Segment routed paths do not encapsulate per hop.
+func (tc *TargetConfig) encapsulators() ([]encapsulator, error) {
+	if tc.Encapsulation == config.EncapsulationSRv6 {
+		return nil, nil
+	}
+
+	ret := make([]encapsulator, 0, len(tc.Hops))
+	for i := range tc.Hops {
+		e, err := getEncapsulator(tc.encapsulationOf(i))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hop %q: %w", tc.Hops[i].Name, err)
 		}
 
-		l = append(l, &layers.IPv6{
-			SrcIP:        t.getSrcAddrHop(i, sequenceNumber),
-			DstIP:        t.getDstAddr(i, sequenceNumber),
-			Version:      6,
-			TrafficClass: t.cfg.TOS.Value,
-			NextHeader:   layers.IPProtocolGRE,
-			HopLimit:     ttl,
-		})
+		err = e.validate(&tc.Hops[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hop %q: %w", tc.Hops[i].Name, err)
+		}
 
-		l = append(l, ipv6inGRE)
+		ret = append(ret, e)
+	}
 
+	return ret, nil
+}
+
+// nextAFI returns the address family of the header following the encapsulation of hop i
+func (t *Target) nextAFI(hop int) uint8 {
+	if hop == len(t.cfg.Hops)-1 {
+		return t.cfg.ReturnAFI
 	}
 
-	ip := &layers.IPv6{
-		SrcIP:        t.getSrcAddrHop(len(t.cfg.Hops), sequenceNumber),
-		DstIP:        t.localAddr,
+	return t.hopAFI(hop + 1)
+}
+
+// hopAFI returns the address family of the header towards hop
+func (t *Target) hopAFI(hop int) uint8 {
+	return afiOf(t.cfg.Hops[hop].DstRange[0])
+}
+
+// ipLayer is an IPv4 or IPv6 header
+type ipLayer interface {
+	gopacket.SerializableLayer
+	gopacket.NetworkLayer
+}
+
+func (t *Target) ipHeader(afi uint8, src net.IP, dst net.IP, proto layers.IPProtocol) ipLayer {
+	if afi == 4 {
+		return &layers.IPv4{
+			SrcIP:    src,
+			DstIP:    dst,
+			Version:  4,
+			Protocol: proto,
+			TOS:      t.cfg.TOS.Value,
+			TTL:      ttl,
+			Flags:    t.ipv4Flags(),
+		}
+	}
+
+	return &layers.IPv6{
+		SrcIP:        src,
+		DstIP:        dst,
 		Version:      6,
 		TrafficClass: t.cfg.TOS.Value,
-		NextHeader:   layers.IPProtocolUDP,
+		NextHeader:   proto,
 		HopLimit:     ttl,
 	}
-	l = append(l, ip)
+}
 
-	udp := &layers.UDP{
-		SrcPort: layers.UDPPort(udpPort),
-		DstPort: layers.UDPPort(udpPort),
+// payload returns the marshaled probe padded to size
+func (t *Target) payload(pr Probe, size uint64) []byte {
+	probeSer := pr.marshal()
+
+	ret := make([]byte, max(size, uint64(len(probeSer))))
+	copy(ret, probeSer[:])
+
+	return ret
+}
+
+// stageOverheads returns for every stage the amount of header bytes of this and all following stages
+func (t *Target) stageOverheads() ([]uint64, error) {
+	stages, err := t.craftStages(0, 0)
+	if err != nil {
+		return nil, err
 	}
 
-	err := udp.SetNetworkLayerForChecksum(ip)
+	ret := make([]uint64, len(stages))
+	sum := uint64(0)
+	for i := len(stages) - 1; i >= 0; i-- {
+		buf := gopacket.NewSerializeBuffer()
+		err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, stages[i]...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to serialize layers: %v", err)
+		}
+
+		sum += uint64(len(buf.Bytes()))
+		ret[i] = sum
+	}
+
+	ret[0] += t.outerHeaderLen()
+	return ret, nil
+}
+
+// overhead returns the amount of header bytes in front of the payload including the outermost IP header
+func (t *Target) overhead() (uint64, error) {
+	overheads, err := t.stageOverheads()
 	if err != nil {
-		return nil, fmt.Errorf("couldn't set the network layer for checksum: %w", err)
+		return 0, err
 	}
-	l = append(l, udp)
 
-	return l, nil
+	return overheads[0], nil
 }
 
-func (t *Target) firstHopAFI() int8 {
-	if t.cfg.Hops[0].DstRange[0].To4() != nil {
-		return 4
+func (t *Target) outerHeaderLen() uint64 {
+	if t.firstHopAFI() == 4 {
+		return ipv4HeaderLen
 	}
 
-	return 6
+	return ipv6HeaderLen
+}
+
+// dryRunTarget returns a target with a placeholder local address to examine the packets of the config
+func (tc *TargetConfig) dryRunTarget() (*Target, error) {
+	encapsulators, err := tc.encapsulators()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Target{
+		cfg:           *tc,
+		localAddr:     net.IPv6unspecified,
+		encapsulators: encapsulators,
+	}
+
+	if t.cfg.ReturnAFI == 0 {
+		t.cfg.ReturnAFI = tc.FirstHopAFI()
+	}
+
+	if t.cfg.ReturnAFI == 4 {
+		t.localAddr = net.IPv4zero
+	}
+
+	return t, nil
+}
+
+// maxPayloadSize returns the largest payload that fits into a packet once all headers are added
+func (tc *TargetConfig) maxPayloadSize() (uint64, error) {
+	t, err := tc.dryRunTarget()
+	if err != nil {
+		return 0, err
+	}
+
+	overhead, err := t.overhead()
+	if err != nil {
+		return 0, err
+	}
+
+	if overhead > maxPacketSize {
+		return 0, nil
+	}
+
+	return maxPacketSize - overhead, nil
+}
+
+// DontFragment tells if the DF bit is to be set on the IPv4 headers of the target's probes
+func (t *Target) DontFragment() bool {
+	return t.cfg.MTUSweep != nil
+}
+
+func (t *Target) ipv4Flags() layers.IPv4Flag {
+	if t.DontFragment() {
+		return layers.IPv4DontFragment
+	}
+
+	return 0
+}
+
+func (t *Target) firstHopAFI() uint8 {
+	return t.cfg.FirstHopAFI()
 }
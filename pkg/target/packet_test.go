@@ -9,16 +9,22 @@ import (
 )
 
 func TestTarget_CraftPacket(t *testing.T) {
+	mtuSweepMaxBytes := uint64(1500)
+	mtuSweepStepBytes := uint64(64)
+	mplsTTL := uint8(64)
+	greKey := uint32(42)
+
 	tests := []struct {
 		name string // description of this test case
 		// Named input parameters for receiver constructor.
 		cfg        TargetConfig
 		returnAddr net.IP
 		// Named input parameters for target function.
-		pr       Probe
-		udpPort  uint16
-		expected []byte
-		wantErr  bool
+		pr          Probe
+		udpPort     uint16
+		payloadSize uint64
+		expected    []byte
+		wantErr     bool
 	}{
 		{
 			name: "basic test ipv4",
@@ -42,7 +48,462 @@ func TestTarget_CraftPacket(t *testing.T) {
 			},
 			udpPort: 33434,
 			expected: []byte{
-				0x0, 0x0, 0x8, 0x0, 0x45, 0x0, 0x0, 0x2c, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x38, 0xc0, 0xc0, 0x0, 0x2, 0x0, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x18, 0xe4, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15,
+				0x0, 0x0, 0x8, 0x0, 0x45, 0x0, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x38, 0xb8, 0xc0, 0x0, 0x2, 0x0, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipv4 with padded payload",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort:     33434,
+			payloadSize: 32,
+			expected: []byte{
+				0x0, 0x0, 0x8, 0x0, 0x45, 0x0, 0x0, 0x3c, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x38, 0xb0, 0xc0, 0x0, 0x2, 0x0, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x28, 0xe3, 0xf5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipv4 MTU sweep sets DF",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.1")},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				MTUSweep: &config.MTUSweep{
+					Mode:      config.MTUSweepModeBinary,
+					MaxBytes:  &mtuSweepMaxBytes,
+					StepBytes: &mtuSweepStepBytes,
+				},
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x0, 0x0, 0x8, 0x0, 0x45, 0x0, 0x0, 0x4c, 0x0, 0x0, 0x40, 0x0, 0x40, 0x2f, 0xce, 0x83, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0x0, 0x0, 0x8, 0x0, 0x45, 0x0, 0x0, 0x34, 0x0, 0x0, 0x40, 0x0, 0x40, 0x11, 0xf8, 0xb6, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipv4 transport returning over ipv6",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				ReturnAFI:           6,
+				ReturnSrcAddrs:      []net.IP{net.ParseIP("fc00::1")},
+			},
+			returnAddr: net.ParseIP("2001:db8::ff"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x0, 0x0, 0x86, 0xdd, 0x60, 0x0, 0x0, 0x0, 0x0, 0x20, 0x11, 0x40, 0xfc, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xfb, 0x4d, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipv4 first hop followed by ipv6 hop",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+					{
+						SrcRange: []net.IP{net.ParseIP("2001:db8::10")},
+						DstRange: []net.IP{net.ParseIP("2001:db8::1")},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				ReturnAFI:           6,
+			},
+			returnAddr: net.ParseIP("2001:db8::ff"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x0, 0x0, 0x86, 0xdd, 0x60, 0x0, 0x0, 0x0, 0x0, 0x4c, 0x2f, 0x40, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x10, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x86, 0xdd, 0x60, 0x0, 0x0, 0x0, 0x0, 0x20, 0x11, 0x40, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x10, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xc9, 0x86, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "gre key, checksum and sequence number",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+						GRE: &config.GRE{
+							Key:      &greKey,
+							Checksum: true,
+							Sequence: true,
+						},
+					},
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.1")},
+						GRE: &config.GRE{
+							Key: &greKey,
+						},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0xb0, 0x0, 0x8, 0x0, 0x61, 0xde, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2a, 0x0, 0x0, 0x0, 0x1, 0x45, 0xb8, 0x0, 0x50, 0x0, 0x0, 0x0, 0x0, 0x40, 0x2f, 0xd, 0xc8, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0x20, 0x0, 0x8, 0x0, 0x0, 0x0, 0x0, 0x2a, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xff, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "mpls over gre hop",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationMPLSOverGRE,
+						MPLS: &config.MPLS{
+							Labels: []uint32{16001, 16002},
+							TTL:    &mplsTTL,
+						},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x0, 0x0, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x54, 0x0, 0x0, 0x0, 0x0, 0x40, 0x2f, 0xd, 0xc4, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0x0, 0x0, 0x88, 0x47, 0x3, 0xe8, 0x1a, 0x40, 0x3, 0xe8, 0x2b, 0x40, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xff, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "mpls in ip hop",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationMPLSInIP,
+						MPLS: &config.MPLS{
+							Labels: []uint32{16001, 16002},
+							TTL:    &mplsTTL,
+						},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x0, 0x0, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x50, 0x0, 0x0, 0x0, 0x0, 0x40, 0x89, 0xd, 0x6e, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0x3, 0xe8, 0x1a, 0x40, 0x3, 0xe8, 0x2b, 0x40, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xff, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "gre in udp hops",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.0")},
+						Encapsulation: config.EncapsulationGREInUDP,
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationGREInUDP,
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0xc0, 0x1, 0x12, 0x92, 0x0, 0x60, 0x24, 0xed, 0x0, 0x0, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x54, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0xd, 0xe2, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0xc0, 0x1, 0x12, 0x92, 0x0, 0x40, 0xfb, 0xe, 0x0, 0x0, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xff, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "mpls in udp path",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.0")},
+						Encapsulation: config.EncapsulationGRE,
+						MPLS: &config.MPLS{
+							Labels: []uint32{16001},
+							TTL:    &mplsTTL,
+						},
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationGRE,
+						MPLS: &config.MPLS{
+							Labels: []uint32{16002},
+							TTL:    &mplsTTL,
+						},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				Encapsulation:       config.EncapsulationMPLSInUDP,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0xc0, 0x1, 0x19, 0xeb, 0x0, 0x60, 0x6, 0x6c, 0x3, 0xe8, 0x1b, 0x40, 0x45, 0xb8, 0x0, 0x54, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0xd, 0xe2, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0xc0, 0x1, 0x19, 0xeb, 0x0, 0x40, 0xcc, 0x8d, 0x3, 0xe8, 0x2b, 0x40, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xff, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "ipip hops returning over ipv6",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.0")},
+						Encapsulation: config.EncapsulationIPIP,
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationIPIP,
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				ReturnAFI:           6,
+				ReturnSrcAddrs:      []net.IP{net.ParseIP("fc00::1")},
+			},
+			returnAddr: net.ParseIP("2001:db8::1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x45, 0xb8, 0x0, 0x5c, 0x0, 0x0, 0x0, 0x0, 0x40, 0x29, 0xd, 0xc2, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0x6b, 0x80, 0x0, 0x0, 0x0, 0x20, 0x11, 0x40, 0xfc, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xfc, 0x4b, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "vxlan and geneve hops",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.0")},
+						Encapsulation: config.EncapsulationVXLAN,
+						Overlay: &config.Overlay{
+							VNI:    10100,
+							SrcMAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+							DstMAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+						},
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationGeneve,
+						Overlay: &config.Overlay{
+							VNI:    10200,
+							SrcMAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+							DstMAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+						},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0xc0, 0x1, 0x12, 0xb5, 0x0, 0x84, 0xa4, 0x69, 0x8, 0x0, 0x0, 0x0, 0x0, 0x27, 0x74, 0x0, 0x2, 0x0, 0x0, 0x0, 0x0, 0x2, 0x2, 0x0, 0x0, 0x0, 0x0, 0x1, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x66, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0xd, 0xd0, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0xc0, 0x1, 0x17, 0xc1, 0x0, 0x52, 0xb4, 0x38, 0x0, 0x0, 0x65, 0x58, 0x0, 0x27, 0xd8, 0x0, 0x2, 0x0, 0x0, 0x0, 0x0, 0x2, 0x2, 0x0, 0x0, 0x0, 0x0, 0x1, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xff, 0xc0, 0x0, 0x2, 0x1, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x4, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "gre, mpls in ip and vxlan hops in one path",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.0")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.0")},
+						Encapsulation: config.EncapsulationGRE,
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.1")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.1")},
+						Encapsulation: config.EncapsulationMPLSInIP,
+						MPLS: &config.MPLS{
+							Labels: []uint32{16001},
+							TTL:    &mplsTTL,
+						},
+					},
+					{
+						SrcRange:      []net.IP{net.ParseIP("192.0.2.2")},
+						DstRange:      []net.IP{net.ParseIP("169.254.0.2")},
+						Encapsulation: config.EncapsulationVXLAN,
+						Overlay: &config.Overlay{
+							VNI:    10100,
+							SrcMAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+							DstMAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+						},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("192.0.2.0")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+			},
+			returnAddr: net.ParseIP("128.0.0.1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x0, 0x0, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x7e, 0x0, 0x0, 0x0, 0x0, 0x40, 0x89, 0xd, 0x40, 0xc0, 0x0, 0x2, 0x0, 0xa9, 0xfe, 0x0, 0x1, 0x3, 0xe8, 0x1b, 0x40, 0x45, 0xb8, 0x0, 0x66, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0xd, 0xce, 0xc0, 0x0, 0x2, 0x1, 0xa9, 0xfe, 0x0, 0x2, 0xc0, 0x1, 0x12, 0xb5, 0x0, 0x52, 0x7a, 0x9c, 0x8, 0x0, 0x0, 0x0, 0x0, 0x27, 0x74, 0x0, 0x2, 0x0, 0x0, 0x0, 0x0, 0x2, 0x2, 0x0, 0x0, 0x0, 0x0, 0x1, 0x8, 0x0, 0x45, 0xb8, 0x0, 0x34, 0x0, 0x0, 0x0, 0x0, 0x40, 0x11, 0x37, 0xfe, 0xc0, 0x0, 0x2, 0x2, 0x80, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xe4, 0x3, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "srv6 path",
+			cfg: TargetConfig{
+				Name: "test-target",
+				TOS:  TOS{Value: 0xb8},
+				Hops: []config.Hop{
+					{
+						SrcRange: []net.IP{net.ParseIP("2001:db8:1::1")},
+						DstRange: []net.IP{net.ParseIP("2001:db8::1")},
+					},
+					{
+						SrcRange: []net.IP{net.ParseIP("2001:db8:1::2")},
+						DstRange: []net.IP{net.ParseIP("2001:db8::2")},
+					},
+				},
+				SrcAddrs:            []net.IP{net.ParseIP("fc00::1")},
+				MeasurementLengthMS: 1000,
+				TimeoutMS:           500,
+				Encapsulation:       config.EncapsulationSRv6,
+			},
+			returnAddr: net.ParseIP("2001:db8:ffff::1"),
+			pr: Probe{
+				SequenceNumber:    1,
+				TimeStampUnixNano: 123456789,
+			},
+			udpPort: 33434,
+			expected: []byte{
+				0x11, 0x6, 0x4, 0x2, 0x2, 0x0, 0x0, 0x0, 0x20, 0x1, 0xd, 0xb8, 0xff, 0xff, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xfc, 0x4b, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 			},
 			wantErr: false,
 		},
@@ -68,7 +529,7 @@ func TestTarget_CraftPacket(t *testing.T) {
 			},
 			udpPort: 33434,
 			expected: []byte{
-				0x0, 0x0, 0x86, 0xdd, 0x60, 0x0, 0x0, 0x0, 0x0, 0x18, 0x11, 0x40, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x18, 0xc9, 0xa5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15,
+				0x0, 0x0, 0x86, 0xdd, 0x60, 0x0, 0x0, 0x0, 0x0, 0x20, 0x11, 0x40, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x20, 0x1, 0xd, 0xb8, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0x82, 0x9a, 0x82, 0x9a, 0x0, 0x20, 0xc9, 0x95, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x7, 0x5b, 0xcd, 0x15, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0,
 			},
 			wantErr: false,
 		},
@@ -80,7 +541,7 @@ func TestTarget_CraftPacket(t *testing.T) {
 			if err != nil {
 				t.Fatalf("could not construct receiver type: %v", err)
 			}
-			got, gotErr := ta.CraftPacket(tt.pr, tt.udpPort)
+			got, gotErr := ta.CraftPacket(tt.pr, tt.udpPort, tt.payloadSize)
 			if gotErr != nil {
 				if !tt.wantErr {
 					t.Errorf("CraftPacket() failed: %v", gotErr)
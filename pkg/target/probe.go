@@ -33,6 +33,8 @@ func getEndianNess() binary.ByteOrder {
 type Probe struct {
 	SequenceNumber    uint64
 	TimeStampUnixNano int64
+	// TargetSequenceNumber counts the probes of the target without gaps caused by other targets
+	TargetSequenceNumber uint64
 }
 
 func Unmarshal(data []byte) (*Probe, error) {
@@ -45,16 +47,20 @@ func Unmarshal(data []byte) (*Probe, error) {
 	return p, nil
 }
 
-func (p *Probe) marshal() [16]byte {
+func (p *Probe) marshal() [24]byte {
 	sn := Uint64Byte(p.SequenceNumber)
 	toBigEndian(sn[:])
 
 	ts := Int64Byte(p.TimeStampUnixNano)
 	toBigEndian(ts[:])
 
-	return [16]byte{
+	tsn := Uint64Byte(p.TargetSequenceNumber)
+	toBigEndian(tsn[:])
+
+	return [24]byte{
 		sn[0], sn[1], sn[2], sn[3], sn[4], sn[5], sn[6], sn[7],
 		ts[0], ts[1], ts[2], ts[3], ts[4], ts[5], ts[6], ts[7],
+		tsn[0], tsn[1], tsn[2], tsn[3], tsn[4], tsn[5], tsn[6], tsn[7],
 	}
 }
 
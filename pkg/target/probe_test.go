@@ -0,0 +1,26 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeMarshal(t *testing.T) {
+	p := Probe{
+		SequenceNumber:       1,
+		TimeStampUnixNano:    123456789,
+		TargetSequenceNumber: 2,
+	}
+
+	b := p.marshal()
+	assert.Equal(t, [24]byte{
+		0, 0, 0, 0, 0, 0, 0, 1,
+		0, 0, 0, 0, 0x07, 0x5b, 0xcd, 0x15,
+		0, 0, 0, 0, 0, 0, 0, 2,
+	}, b)
+
+	got, err := Unmarshal(b[:])
+	assert.NoError(t, err)
+	assert.Equal(t, &p, got)
+}
@@ -0,0 +1,73 @@
+package target
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// reorderingHistory is the number of arrivals kept to determine the reordering extent
+	reorderingHistory = 1024
+)
+
+// reorderingDetector detects reordered probes by their per target sequence numbers (RFC 4737).
+// A probe is reordered if its sequence number is lower than the next expected one.
+type reorderingDetector struct {
+	mu      sync.Mutex
+	nextExp uint64
+	// arrivals is a ring buffer of the sequence numbers of the last arrived probes
+	arrivals []uint64
+	// total number of arrivals
+	n         uint64
+	reordered uint64
+	extentSum uint64
+}
+
+func newReorderingDetector() *reorderingDetector {
+	return &reorderingDetector{
+		arrivals: make([]uint64, reorderingHistory),
+	}
+}
+
+// arrived records the arrival of the probe with sequence number seq and returns its reordering extent.
+// The extent is 0 if the probe is in order. It is capped at the history size.
+func (r *reorderingDetector) arrived(seq uint64) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	extent := uint64(0)
+	if seq >= r.nextExp {
+		r.nextExp = seq + 1
+	} else {
+		extent = r.extent(seq)
+		atomic.AddUint64(&r.reordered, 1)
+		atomic.AddUint64(&r.extentSum, extent)
+	}
+
+	r.arrivals[r.n%reorderingHistory] = seq
+	r.n++
+
+	return extent
+}
+
+// extent returns the number of arrivals since the earliest arrival of a probe with a sequence number above seq
+func (r *reorderingDetector) extent(seq uint64) uint64 {
+	kept := min(r.n, reorderingHistory)
+	for i := r.n - kept; i < r.n; i++ {
+		if r.arrivals[i%reorderingHistory] > seq {
+			return r.n - i
+		}
+	}
+
+	return kept
+}
+
+// ProbeArrived tracks the order in which the probes of the target arrive
+func (t *Target) ProbeArrived(seq uint64) {
+	t.reordering.arrived(seq)
+}
+
+// Reordering returns the number of reordered probes and the sum of their reordering extents
+func (t *Target) Reordering() (uint64, uint64) {
+	return atomic.LoadUint64(&t.reordering.reordered), atomic.LoadUint64(&t.reordering.extentSum)
+}
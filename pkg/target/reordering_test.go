@@ -0,0 +1,74 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorderingDetector(t *testing.T) {
+	tests := []struct {
+		name              string
+		arrivals          []uint64
+		expectedExtents   []uint64
+		expectedReordered uint64
+		expectedExtentSum uint64
+	}{
+		{
+			name:              "in order",
+			arrivals:          []uint64{0, 1, 2, 3},
+			expectedExtents:   []uint64{0, 0, 0, 0},
+			expectedReordered: 0,
+			expectedExtentSum: 0,
+		},
+		{
+			name:              "lost probes are not reordered",
+			arrivals:          []uint64{0, 2, 5},
+			expectedExtents:   []uint64{0, 0, 0},
+			expectedReordered: 0,
+			expectedExtentSum: 0,
+		},
+		{
+			name:              "swapped probes",
+			arrivals:          []uint64{0, 2, 1, 3},
+			expectedExtents:   []uint64{0, 0, 1, 0},
+			expectedReordered: 1,
+			expectedExtentSum: 1,
+		},
+		{
+			name:              "late probe",
+			arrivals:          []uint64{1, 2, 3, 0, 4},
+			expectedExtents:   []uint64{0, 0, 0, 3, 0},
+			expectedReordered: 1,
+			expectedExtentSum: 3,
+		},
+		{
+			name:              "multiple reordered probes",
+			arrivals:          []uint64{0, 3, 1, 2, 4},
+			expectedExtents:   []uint64{0, 0, 1, 2, 0},
+			expectedReordered: 2,
+			expectedExtentSum: 3,
+		},
+	}
+
+	for _, test := range tests {
+		r := newReorderingDetector()
+		extents := make([]uint64, 0, len(test.arrivals))
+		for _, seq := range test.arrivals {
+			extents = append(extents, r.arrived(seq))
+		}
+
+		assert.Equal(t, test.expectedExtents, extents, test.name)
+		assert.Equal(t, test.expectedReordered, r.reordered, test.name)
+		assert.Equal(t, test.expectedExtentSum, r.extentSum, test.name)
+	}
+}
+
+func TestReorderingDetectorHistory(t *testing.T) {
+	r := newReorderingDetector()
+	for seq := uint64(1); seq <= 2*reorderingHistory; seq++ {
+		r.arrived(seq)
+	}
+
+	assert.Equal(t, uint64(reorderingHistory), r.arrived(0))
+}
@@ -0,0 +1,119 @@
+package target
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	srhRoutingType = 4
+	srhFixedLen    = 8
+
+	// maxSegments is the largest segment list the header extension length field can describe
+	maxSegments = 255 / 2
+)
+
+// segmentRoutingHeader is an IPv6 segment routing header (RFC 8754). gopacket can only decode routing headers.
+type segmentRoutingHeader struct {
+	NextHeader layers.IPProtocol
+	// Segments in the order they are visited
+	Segments []net.IP
+}
+
+func (s *segmentRoutingHeader) LayerType() gopacket.LayerType {
+	return layers.LayerTypeIPv6Routing
+}
+
+func (s *segmentRoutingHeader) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	n := len(s.Segments)
+	if n == 0 || n > maxSegments {
+		return fmt.Errorf("number of segments must be between 1 and %d, got %d", maxSegments, n)
+	}
+
+	bytes, err := b.PrependBytes(srhFixedLen + n*net.IPv6len)
+	if err != nil {
+		return err
+	}
+
+	bytes[0] = byte(s.NextHeader)
+	bytes[1] = byte(2 * n)
+	bytes[2] = srhRoutingType
+	bytes[3] = byte(n - 1) // segments left
+	bytes[4] = byte(n - 1) // last entry
+	bytes[5] = 0           // flags
+	bytes[6] = 0           // tag
+	bytes[7] = 0
+
+	// The segment list is encoded in reverse order
+	for i, seg := range s.Segments {
+		ip := seg.To16()
+		if ip == nil || seg.To4() != nil {
+			return fmt.Errorf("segment %s is not an IPv6 address", seg)
+		}
+
+		copy(bytes[srhFixedLen+(n-1-i)*net.IPv6len:], ip)
+	}
+
+	return nil
+}
+
+func (t *Target) srv6() bool {
+	return t.cfg.Encapsulation == config.EncapsulationSRv6
+}
+
+// craftSRv6Stages returns the layers of a segment routed probe. The outermost IPv6 header carries the first segment as destination,
+// the prober is the last segment. There is only one stage as the headers do not change along the path.
+func (t *Target) craftSRv6Stages(sequenceNumber uint64, udpPort uint16) ([][]gopacket.SerializableLayer, error) {
+	srh := &segmentRoutingHeader{
+		NextHeader: layers.IPProtocolUDP,
+		Segments:   make([]net.IP, 0, len(t.cfg.Hops)+1),
+	}
+
+	for i := range t.cfg.Hops {
+		srh.Segments = append(srh.Segments, t.getDstAddr(i, sequenceNumber))
+	}
+	srh.Segments = append(srh.Segments, t.localAddr)
+
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(udpPort),
+		DstPort: layers.UDPPort(udpPort),
+	}
+
+	// The pseudo header of the UDP checksum carries the final destination (RFC 8200)
+	err := udp.SetNetworkLayerForChecksum(&layers.IPv6{
+		SrcIP: t.cfg.GetSrcAddr(sequenceNumber),
+		DstIP: t.localAddr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't set the network layer for checksum: %w", err)
+	}
+
+	return [][]gopacket.SerializableLayer{{srh, udp}}, nil
+}
+
+// validateSRv6 checks that all headers of a segment routed target are IPv6
+func (tc *TargetConfig) validateSRv6() error {
+	if len(tc.Hops)+1 > maxSegments {
+		return fmt.Errorf("srv6 supports at most %d hops", maxSegments-1)
+	}
+
+	for _, h := range tc.Hops {
+		if afiOf(h.DstRange[0]) != 6 {
+			return fmt.Errorf("dst_range of hop %q is not IPv6", h.Name)
+		}
+	}
+
+	if tc.ReturnAFI != 6 {
+		return fmt.Errorf("return_afi must be 6, got %d", tc.ReturnAFI)
+	}
+
+	if afiOf(tc.SrcAddrs[0]) != 6 {
+		return fmt.Errorf("src_range is not IPv6")
+	}
+
+	return nil
+}
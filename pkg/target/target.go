@@ -1,13 +1,21 @@
 package target
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"slices"
+	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/bio-routing/matroschka-prober/pkg/config"
 )
 
+const (
+	maxSizeScheduleLen = 1 << 16
+)
+
 type Label struct {
 	Key   string
 	Value string
@@ -16,20 +24,57 @@ type Label struct {
 type TargetID struct {
 	Path string
 	TOS  TOS
+	Size uint64
 }
 
 // Target keeps the state of a target instance. There is one instance per probed path.
 type Target struct {
-	cfg         TargetConfig
-	localAddr   net.IP
-	latePackets uint64
+	cfg            TargetConfig
+	localAddr      net.IP
+	totals         totals
+	duplicates     uint64
+	seq            uint64
+	reordering     *reorderingDetector
+	dupDetector    *duplicateDetector
+	lossBursts     *lossBurstTracker
+	delayVariation *delayVariation
+	sweeper        *mtuSweeper
+	encapsulators  []encapsulator
+	histogram      *rttHistogram
 }
 
 func NewTarget(cfg TargetConfig, localAddr net.IP) (*Target, error) {
-	return &Target{
-		cfg:       cfg,
-		localAddr: localAddr,
-	}, nil
+	if cfg.ReturnAFI == 0 {
+		cfg.ReturnAFI = cfg.FirstHopAFI()
+	}
+
+	encapsulators, err := cfg.encapsulators()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get encapsulators: %w", err)
+	}
+
+	t := &Target{
+		cfg:            cfg,
+		localAddr:      localAddr,
+		encapsulators:  encapsulators,
+		reordering:     newReorderingDetector(),
+		dupDetector:    newDuplicateDetector(),
+		lossBursts:     newLossBurstTracker(),
+		delayVariation: newDelayVariation(),
+	}
+
+	if len(cfg.HistogramBuckets) > 0 {
+		t.histogram = newRTTHistogram(cfg.HistogramBuckets)
+	}
+
+	if cfg.MTUSweep != nil {
+		t.sweeper, err = t.newMTUSweeper()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create MTU sweeper: %w", err)
+		}
+	}
+
+	return t, nil
 }
 
 func (t *Target) Config() TargetConfig {
@@ -45,13 +90,62 @@ type TargetConfig struct {
 	StaticLabels        []Label
 	MeasurementLengthMS uint64
 	TimeoutMS           uint64
+	PayloadSizeBytes    uint64
+	// SizeSchedule is the interleaved sequence of payload sizes of the path's size distribution
+	SizeSchedule []uint64
+	MTUSweep     *config.MTUSweep
+	// ReturnAFI is the address family of the returning packet
+	ReturnAFI uint8
+	// ReturnSrcAddrs are the source addresses of the returning packet if they can not be taken from the last hop
+	ReturnSrcAddrs []net.IP
+	// Encapsulation of the whole path replacing the encapsulation of the hops
+	Encapsulation string
+	// Quantiles of the RTT to export
+	Quantiles []float64
+	// QuantileRelativeError is the relative accuracy of the quantile sketches
+	QuantileRelativeError float64
+	// HistogramBuckets are the upper bounds in seconds of the RTT histogram
+	HistogramBuckets []float64
+	// Codec of the class to rate the voice quality with. Empty if the class carries no voice.
+	Codec string
+	// WindowsMS are the windows the measurements are aggregated over in addition to the measurement length
+	WindowsMS []uint64
 }
 
 func (tc *TargetConfig) GetID() TargetID {
-	return TargetID{
+	id := TargetID{
 		Path: tc.Name,
 		TOS:  tc.TOS,
 	}
+
+	if tc.sizeDistributed() {
+		id.Size = tc.PayloadSizeBytes
+	}
+
+	return id
+}
+
+// SendAt tells if a probe is due for the target at the given tick of the sender
+func (tc *TargetConfig) SendAt(tick uint64) bool {
+	if !tc.sizeDistributed() {
+		return true
+	}
+
+	return tc.SizeSchedule[tick%uint64(len(tc.SizeSchedule))] == tc.PayloadSizeBytes
+}
+
+func (tc *TargetConfig) sizeDistributed() bool {
+	return len(tc.SizeSchedule) > 0
+}
+
+// FirstHopAFI returns the address family of the first hop
+func (tc *TargetConfig) FirstHopAFI() uint8 {
+	return afiOf(tc.Hops[0].DstRange[0])
+}
+
+// Windows returns the lengths of all windows the measurements are aggregated over in milliseconds
+func (tc *TargetConfig) Windows() []uint64 {
+	return append([]uint64{tc.MeasurementLengthMS}, tc.WindowsMS...)
 }
 
 func (tc *TargetConfig) GetSrcAddr(s uint64) net.IP {
@@ -68,65 +162,374 @@ func (c *TargetConfig) Equal(b *TargetConfig) bool {
 
 	return c.MeasurementLengthMS == b.MeasurementLengthMS &&
 		c.TimeoutMS == b.TimeoutMS &&
+		c.PayloadSizeBytes == b.PayloadSizeBytes &&
+		slices.Equal(c.SizeSchedule, b.SizeSchedule) &&
+		mtuSweepsEqual(c.MTUSweep, b.MTUSweep) &&
+		c.ReturnAFI == b.ReturnAFI &&
+		config.IPListsEqual(c.SrcAddrs, b.SrcAddrs) &&
+		config.IPListsEqual(c.ReturnSrcAddrs, b.ReturnSrcAddrs) &&
+		c.Encapsulation == b.Encapsulation &&
+		slices.Equal(c.Quantiles, b.Quantiles) &&
+		c.QuantileRelativeError == b.QuantileRelativeError &&
+		slices.Equal(c.HistogramBuckets, b.HistogramBuckets) &&
+		c.Codec == b.Codec &&
+		slices.Equal(c.WindowsMS, b.WindowsMS) &&
 		config.HopListsEqual(c.Hops, b.Hops) &&
 		slices.Equal(c.StaticLabels, b.StaticLabels)
 }
 
-func (t *Target) LatePacket() {
-	atomic.AddUint64(&t.latePackets, 1)
+func mtuSweepsEqual(a, b *config.MTUSweep) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Mode == b.Mode &&
+		a.MinBytes == b.MinBytes &&
+		*a.MaxBytes == *b.MaxBytes &&
+		*a.StepBytes == *b.StepBytes
 }
 
-func (t *Target) GetLatePackets() uint64 {
-	return atomic.LoadUint64(&t.latePackets)
+// NextSequenceNumber returns the per target sequence number of the next probe. Unlike the sequence numbers of the probes it has no gaps
+// caused by other targets, so consecutive probes of a target can be identified. It only advances with AdvanceSequenceNumber,
+// so a probe that fails to be sent leaves no gap either.
+func (t *Target) NextSequenceNumber() uint64 {
+	return atomic.LoadUint64(&t.seq)
+}
+
+// AdvanceSequenceNumber consumes the sequence number returned by NextSequenceNumber once its probe was sent
+func (t *Target) AdvanceSequenceNumber() {
+	atomic.AddUint64(&t.seq, 1)
+}
+
+// DuplicatePacket counts a probe that was received more than once
+func (t *Target) DuplicatePacket() {
+	atomic.AddUint64(&t.duplicates, 1)
+}
+
+func (t *Target) GetDuplicatePackets() uint64 {
+	return atomic.LoadUint64(&t.duplicates)
 }
 
 func (t *Target) Labels() []string {
-	keys := make([]string, 0, len(t.cfg.StaticLabels)+2)
+	keys := make([]string, 0, len(t.cfg.StaticLabels)+3)
 	for _, l := range t.cfg.StaticLabels {
 		keys = append(keys, l.Key)
 	}
 
 	keys = append(keys, "tos")
 	keys = append(keys, "path")
+	if t.cfg.sizeDistributed() {
+		keys = append(keys, "size")
+	}
+
 	return keys
 }
 
 func (t *Target) LabelValues() []string {
-	values := make([]string, 0, len(t.cfg.StaticLabels)+2)
+	values := make([]string, 0, len(t.cfg.StaticLabels)+3)
 	for _, l := range t.cfg.StaticLabels {
 		values = append(values, l.Value)
 	}
 
 	values = append(values, t.cfg.TOS.Name)
 	values = append(values, t.cfg.Name)
+	if t.cfg.sizeDistributed() {
+		values = append(values, strconv.FormatUint(t.cfg.PayloadSizeBytes, 10))
+	}
+
 	return values
 }
 
-func Targets(p config.Path, c *config.Config) []TargetConfig {
+// settingError returns an error of a setting of the path, so it is reported at the setting in the config
+func settingError(p config.Path, setting string, err error) error {
+	return &config.SettingError{
+		Path:    p.Name,
+		Setting: setting,
+		Err:     err,
+	}
+}
+
+// Targets generates the target configs of a path, one per class and payload size
+func Targets(p config.Path, c *config.Config) ([]TargetConfig, error) {
+	errs := make([]error, 0)
+	if len(p.SizeDistribution) > 0 && p.MTUSweep != nil {
+		errs = append(errs, fmt.Errorf("path %q can not combine a size distribution with an MTU sweep", p.Name))
+	}
+
+	hops, err := c.PathToProberHops(p)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to get hops of path %q: %w", p.Name, err))
+	} else if len(hops) == 0 {
+		errs = append(errs, fmt.Errorf("path %q has no hops", p.Name))
+	}
+
+	for _, q := range p.Quantiles {
+		if q < 0 || q > 1 {
+			errs = append(errs, settingError(p, "quantiles", fmt.Errorf("quantile %v is not within [0, 1]", q)))
+			break
+		}
+	}
+
+	if *p.QuantileRelativeError <= 0 || *p.QuantileRelativeError >= 1 {
+		errs = append(errs, settingError(p, "quantile_relative_error", fmt.Errorf("%v is not within (0, 1)", *p.QuantileRelativeError)))
+	}
+
+	err = validateHistogramBuckets(p.HistogramBuckets)
+	if err != nil {
+		errs = append(errs, settingError(p, "histogram_buckets", err))
+	}
+
+	err = validateWindows(*p.MeasurementLengthMS, p.WindowsMS)
+	if err != nil {
+		errs = append(errs, settingError(p, "windows_ms", err))
+	}
+
+	var returnAFI uint8
+	var returnSrcAddrs []net.IP
+	if len(hops) > 0 {
+		returnAFI, returnSrcAddrs, err = returnConfig(p, hops)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid return config of path %q: %w", p.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	srcAddrs := config.GenerateAddrs(c.SrcRange)
+	sizes := []uint64{*p.PayloadSizeBytes}
+	var schedule []uint64
+	if len(p.SizeDistribution) > 0 {
+		dist := p.SizeDistribution
+		if p.SizesIncludeHeaders {
+			tc := TargetConfig{
+				Hops:           hops,
+				SrcAddrs:       srcAddrs,
+				ReturnAFI:      returnAFI,
+				ReturnSrcAddrs: returnSrcAddrs,
+				Encapsulation:  p.Encapsulation,
+			}
+
+			dist, err = tc.payloadSizes(dist)
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine payload sizes of path %q: %w", p.Name, err)
+			}
+		}
+
+		schedule, err = sizeSchedule(dist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size distribution of path %q: %w", p.Name, err)
+		}
+
+		sizes = make([]uint64, 0, len(dist))
+		for _, ps := range dist {
+			sizes = append(sizes, ps.Size)
+		}
+	}
+
 	ret := make([]TargetConfig, 0)
 	for _, class := range c.Classes {
-		hops, err := c.PathToProberHops(p)
-		if err != nil {
-			panic(err)
-		}
-
-		ret = append(ret, TargetConfig{
-			Name: p.Name,
-			TOS: TOS{
-				Name:  class.Name,
-				Value: class.TOS,
-			},
-			Hops:                hops,
-			SrcAddrs:            config.GenerateAddrs(c.SrcRange),
-			StaticLabels:        convertLabels(p.Labels),
-			MeasurementLengthMS: *p.MeasurementLengthMS,
-			TimeoutMS:           *p.TimeoutMS,
+
+		for _, size := range sizes {
+			tc := TargetConfig{
+				Name: p.Name,
+				TOS: TOS{
+					Name:  class.Name,
+					Value: class.TOS,
+				},
+				Hops:                  hops,
+				SrcAddrs:              srcAddrs,
+				StaticLabels:          convertLabels(p.Labels),
+				MeasurementLengthMS:   *p.MeasurementLengthMS,
+				TimeoutMS:             *p.TimeoutMS,
+				PayloadSizeBytes:      size,
+				SizeSchedule:          schedule,
+				MTUSweep:              p.MTUSweep,
+				ReturnAFI:             returnAFI,
+				ReturnSrcAddrs:        returnSrcAddrs,
+				Encapsulation:         p.Encapsulation,
+				Quantiles:             p.Quantiles,
+				QuantileRelativeError: *p.QuantileRelativeError,
+				HistogramBuckets:      p.HistogramBuckets,
+				Codec:                 class.Codec,
+				WindowsMS:             p.WindowsMS,
+			}
+
+			if tc.Codec != "" {
+				_, err = getCodec(tc.Codec)
+				if err != nil {
+					return nil, fmt.Errorf("invalid class %q: %w", class.Name, err)
+				}
+			}
+
+			_, err = tc.encapsulators()
+			if err != nil {
+				return nil, fmt.Errorf("invalid encapsulation of path %q: %w", p.Name, err)
+			}
+
+			if tc.Encapsulation == config.EncapsulationSRv6 {
+				err = tc.validateSRv6()
+				if err != nil {
+					return nil, fmt.Errorf("invalid srv6 path %q: %w", p.Name, err)
+				}
+			}
+
+			maxPayloadSize, err := tc.maxPayloadSize()
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine maximum payload size of path %q: %w", p.Name, err)
+			}
+
+			if tc.PayloadSizeBytes > maxPayloadSize {
+				return nil, fmt.Errorf("payload size %d of path %q exceeds the maximum of %d bytes", tc.PayloadSizeBytes, p.Name, maxPayloadSize)
+			}
+
+			if tc.MTUSweep != nil {
+				if *tc.MTUSweep.MaxBytes > maxPacketSize {
+					return nil, fmt.Errorf("max_bytes %d of the MTU sweep of path %q exceeds the maximum of %d bytes", *tc.MTUSweep.MaxBytes, p.Name, maxPacketSize)
+				}
+
+				t, err := tc.dryRunTarget()
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: %w", p.Name, err)
+				}
+
+				_, err = t.newMTUSweeper()
+				if err != nil {
+					return nil, fmt.Errorf("invalid MTU sweep of path %q: %w", p.Name, err)
+				}
+			}
+
+			ret = append(ret, tc)
+		}
+	}
+
+	return ret, nil
+}
+
+// payloadSizes converts a distribution of packet sizes into payload sizes of the probes.
+// Packets smaller than the headers of the probes are sent as the smallest possible probe.
+func (tc *TargetConfig) payloadSizes(dist []config.PacketSize) ([]config.PacketSize, error) {
+	t, err := tc.dryRunTarget()
+	if err != nil {
+		return nil, err
+	}
+
+	overhead, err := t.overhead()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine overhead: %w", err)
+	}
+
+	ret := make([]config.PacketSize, 0, len(dist))
+	for _, ps := range dist {
+		size := uint64(0)
+		if ps.Size > overhead {
+			size = ps.Size - overhead
+		}
+
+		ret = append(ret, config.PacketSize{
+			Size:   size,
+			Weight: ps.Weight,
 		})
 	}
 
-	return ret
+	return ret, nil
+}
+
+// returnConfig returns the address family of the returning packet and its source addresses if they can not be taken from the last hop
+func returnConfig(p config.Path, hops []config.Hop) (uint8, []net.IP, error) {
+	for _, h := range hops {
+		if afiOf(h.SrcRange[0]) != afiOf(h.DstRange[0]) {
+			return 0, nil, fmt.Errorf("src_range and dst_range of router %q belong to different address families", h.Name)
+		}
+	}
+
+	lastHopAFI := afiOf(hops[len(hops)-1].SrcRange[0])
+	returnAFI := p.ReturnAFI
+	if returnAFI == 0 {
+		returnAFI = afiOf(hops[0].DstRange[0])
+	}
+
+	if returnAFI != 4 && returnAFI != 6 {
+		return 0, nil, fmt.Errorf("return_afi must be 4 or 6, got %d", returnAFI)
+	}
+
+	if p.ReturnSrcRange != nil {
+		if config.GetIPVersion(p.ReturnSrcRange) != returnAFI {
+			return 0, nil, fmt.Errorf("return_src_range %s does not match return_afi %d", p.ReturnSrcRange, returnAFI)
+		}
+
+		return returnAFI, config.GenerateAddrs(p.ReturnSrcRange), nil
+	}
+
+	if returnAFI == lastHopAFI {
+		return returnAFI, nil, nil
+	}
+
+	return returnAFI, config.GenerateAddrs(config.DefaultSrcRange(returnAFI)), nil
+}
+
+func afiOf(addr net.IP) uint8 {
+	if addr.To4() != nil {
+		return 4
+	}
+
+	return 6
+}
+
+// sizeSchedule interleaves the sizes of a distribution according to their weights (smooth weighted round robin)
+func sizeSchedule(dist []config.PacketSize) ([]uint64, error) {
+	divisor := uint64(0)
+	for i, ps := range dist {
+		if ps.Weight == 0 {
+			return nil, fmt.Errorf("weight of size %d must not be 0", ps.Size)
+		}
+
+		for _, other := range dist[:i] {
+			if other.Size == ps.Size {
+				return nil, fmt.Errorf("size %d is listed more than once", ps.Size)
+			}
+		}
+
+		divisor = gcd(divisor, ps.Weight)
+	}
+
+	total := uint64(0)
+	for _, ps := range dist {
+		total += ps.Weight / divisor
+	}
+
+	if total > maxSizeScheduleLen {
+		return nil, fmt.Errorf("sum of reduced weights %d exceeds the maximum of %d", total, maxSizeScheduleLen)
+	}
+
+	current := make([]int64, len(dist))
+	ret := make([]uint64, 0, total)
+	for range total {
+		best := 0
+		for i, ps := range dist {
+			current[i] += int64(ps.Weight / divisor)
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+
+		current[best] -= int64(total)
+		ret = append(ret, dist[best].Size)
+	}
+
+	return ret, nil
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
 }
 
+// convertLabels returns the labels sorted by key, so the labels of an unchanged path compare equal across reloads
 func convertLabels(kv map[string]string) []Label {
 	labels := make([]Label, 0, len(kv))
 	for k, v := range kv {
@@ -135,6 +538,11 @@ func convertLabels(kv map[string]string) []Label {
 			Value: v,
 		})
 	}
+
+	slices.SortFunc(labels, func(a, b Label) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
 	return labels
 }
 
@@ -145,3 +553,26 @@ func (t *Target) TimedOut(s int64) bool {
 func msToNS(s uint64) uint64 {
 	return s * 1000000
 }
+
+// validateWindows checks that all windows are distinct multiples of the measurement length
+func validateWindows(measurementLengthMS uint64, windowsMS []uint64) error {
+	if measurementLengthMS == 0 && len(windowsMS) > 0 {
+		return fmt.Errorf("windows require a measurement length")
+	}
+
+	for i, w := range windowsMS {
+		if w <= measurementLengthMS {
+			return fmt.Errorf("window %d ms must be longer than the measurement length of %d ms", w, measurementLengthMS)
+		}
+
+		if w%measurementLengthMS != 0 {
+			return fmt.Errorf("window %d ms is not a multiple of the measurement length of %d ms", w, measurementLengthMS)
+		}
+
+		if slices.Contains(windowsMS[:i], w) {
+			return fmt.Errorf("window %d ms is configured more than once", w)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,490 @@
+package target
+
+import (
+	"net"
+	"testing"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargets(t *testing.T) {
+	tests := []struct {
+		name             string
+		payloadSizeBytes uint64
+		encapsulation    string
+		wantErr          bool
+	}{
+		{
+			name:             "payload fits",
+			payloadSizeBytes: 1500,
+			wantErr:          false,
+		},
+		{
+			name:             "largest payload that fits",
+			payloadSizeBytes: 65535 - 20 - 4 - 20 - 8,
+			wantErr:          false,
+		},
+		{
+			name:             "payload exceeds encapsulation overhead",
+			payloadSizeBytes: 65535 - 20 - 4 - 20 - 8 + 1,
+			wantErr:          true,
+		},
+		{
+			name:             "path encapsulation overrides router",
+			payloadSizeBytes: 65535 - 20 - 20 - 8,
+			encapsulation:    config.EncapsulationIPIP,
+			wantErr:          false,
+		},
+		{
+			name:             "unknown path encapsulation",
+			payloadSizeBytes: 0,
+			encapsulation:    "pigeon",
+			wantErr:          true,
+		},
+		{
+			name:             "path encapsulation lacks router parameters",
+			payloadSizeBytes: 0,
+			encapsulation:    config.EncapsulationMPLSInIP,
+			wantErr:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config.Config{
+				Classes: []config.Class{
+					{
+						Name: "BE",
+					},
+				},
+				Routers: []config.Router{
+					{
+						Name:     "r1",
+						DstRange: parseNetwork("169.254.0.0/32"),
+						SrcRange: parseNetwork("192.0.2.0/32"),
+					},
+				},
+				SrcRange: parseNetwork("192.0.2.0/32"),
+			}
+			measurementLengthMS := uint64(1000)
+			timeoutMS := uint64(500)
+			quantileRelativeError := 0.01
+			p := config.Path{
+				Name:                  "test-path",
+				Hops:                  []string{"r1"},
+				MeasurementLengthMS:   &measurementLengthMS,
+				TimeoutMS:             &timeoutMS,
+				QuantileRelativeError: &quantileRelativeError,
+				PayloadSizeBytes:      &tt.payloadSizeBytes,
+				Encapsulation:         tt.encapsulation,
+			}
+
+			tcs, err := Targets(p, c)
+			if tt.wantErr {
+				assert.Error(t, err, tt.name)
+				return
+			}
+
+			assert.NoError(t, err, tt.name)
+			assert.Len(t, tcs, 1, tt.name)
+			assert.Equal(t, tt.payloadSizeBytes, tcs[0].PayloadSizeBytes, tt.name)
+		})
+	}
+}
+
+func TestTargetsIMIX(t *testing.T) {
+	c := &config.Config{
+		Paths: []config.Path{
+			{
+				Name: "test-path",
+				Hops: []string{"r1"},
+				IMIX: true,
+			},
+		},
+		Routers: []config.Router{
+			{
+				Name:        "r1",
+				DstRangeStr: "169.254.0.0/32",
+				SrcRangeStr: "192.0.2.0/32",
+			},
+		},
+	}
+
+	err := c.ApplyDefaults()
+	assert.NoError(t, err)
+	err = c.ConvertIPAddresses()
+	assert.NoError(t, err)
+
+	tcs, err := Targets(c.Paths[0], c)
+	assert.NoError(t, err)
+	assert.Len(t, tcs, 3)
+
+	// IMIX sizes are sizes of whole packets on the wire
+	wireSizes := make([]int, 0, len(tcs))
+	for _, tc := range tcs {
+		ta, err := NewTarget(tc, net.ParseIP("128.0.0.1"))
+		assert.NoError(t, err)
+
+		pkt, err := ta.CraftPacket(Probe{}, 33434, tc.PayloadSizeBytes)
+		assert.NoError(t, err)
+
+		// The outer IPv4 header is added by the raw socket
+		wireSizes = append(wireSizes, len(pkt)+ipv4HeaderLen)
+	}
+
+	// The headers of the probe alone exceed 64 bytes, so the smallest possible probe is sent
+	assert.Equal(t, []int{76, 576, 1500}, wireSizes)
+}
+
+func TestTargetsErrors(t *testing.T) {
+	c := &config.Config{
+		Paths: []config.Path{
+			{
+				Name:      "test-path",
+				Hops:      []string{"r1"},
+				Quantiles: []float64{1.5},
+				WindowsMS: []uint64{1500},
+			},
+		},
+		Routers: []config.Router{
+			{
+				Name:        "r1",
+				DstRangeStr: "169.254.0.0/32",
+				SrcRangeStr: "192.0.2.0/32",
+			},
+		},
+	}
+
+	err := c.ApplyDefaults()
+	assert.NoError(t, err)
+	err = c.ConvertIPAddresses()
+	assert.NoError(t, err)
+
+	// All errors of the path are reported, each along with its setting
+	_, err = Targets(c.Paths[0], c)
+	assert.EqualError(t, err, `invalid quantiles of path "test-path": quantile 1.5 is not within [0, 1]
+invalid windows_ms of path "test-path": window 1500 ms is not a multiple of the measurement length of 1000 ms`)
+
+	var se *config.SettingError
+	assert.ErrorAs(t, err, &se)
+	assert.Equal(t, "quantiles", se.Setting)
+}
+
+func parseNetwork(network string) *net.IPNet {
+	_, ret, _ := net.ParseCIDR(network)
+	return ret
+}
+
+func TestSizeSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		dist     []config.PacketSize
+		expected []uint64
+		wantErr  bool
+	}{
+		{
+			name: "classic IMIX",
+			dist: []config.PacketSize{
+				{
+					Size:   64,
+					Weight: 7,
+				},
+				{
+					Size:   576,
+					Weight: 4,
+				},
+				{
+					Size:   1500,
+					Weight: 1,
+				},
+			},
+			expected: []uint64{64, 576, 64, 64, 576, 64, 1500, 64, 576, 64, 576, 64},
+		},
+		{
+			name: "weights are reduced",
+			dist: []config.PacketSize{
+				{
+					Size:   100,
+					Weight: 20,
+				},
+				{
+					Size:   200,
+					Weight: 10,
+				},
+			},
+			expected: []uint64{100, 200, 100},
+		},
+		{
+			name: "zero weight",
+			dist: []config.PacketSize{
+				{
+					Size:   100,
+					Weight: 0,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate size",
+			dist: []config.PacketSize{
+				{
+					Size:   100,
+					Weight: 1,
+				},
+				{
+					Size:   100,
+					Weight: 2,
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sizeSchedule(tt.dist)
+			if tt.wantErr {
+				assert.Error(t, err, tt.name)
+				return
+			}
+
+			assert.NoError(t, err, tt.name)
+			assert.Equal(t, tt.expected, got, tt.name)
+		})
+	}
+}
+
+func TestReturnConfig(t *testing.T) {
+	v4Hops := []config.Hop{
+		{
+			DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+			SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		path            config.Path
+		hops            []config.Hop
+		expectedAFI     uint8
+		expectedSrcAddr net.IP
+		wantErr         bool
+	}{
+		{
+			name:        "defaults to first hop AFI",
+			path:        config.Path{},
+			hops:        v4Hops,
+			expectedAFI: 4,
+		},
+		{
+			name: "IPv6 return uses default IPv6 source range",
+			path: config.Path{
+				ReturnAFI: 6,
+			},
+			hops:            v4Hops,
+			expectedAFI:     6,
+			expectedSrcAddr: net.ParseIP("fc00::"),
+		},
+		{
+			name: "IPv6 return with configured source range",
+			path: config.Path{
+				ReturnAFI:      6,
+				ReturnSrcRange: parseNetwork("2001:db8::1/128"),
+			},
+			hops:            v4Hops,
+			expectedAFI:     6,
+			expectedSrcAddr: net.ParseIP("2001:db8::1"),
+		},
+		{
+			name: "source range AFI mismatch",
+			path: config.Path{
+				ReturnAFI:      6,
+				ReturnSrcRange: parseNetwork("192.0.2.0/32"),
+			},
+			hops:    v4Hops,
+			wantErr: true,
+		},
+		{
+			name: "invalid AFI",
+			path: config.Path{
+				ReturnAFI: 5,
+			},
+			hops:    v4Hops,
+			wantErr: true,
+		},
+		{
+			name: "hop src and dst range AFI mismatch",
+			path: config.Path{},
+			hops: []config.Hop{
+				{
+					DstRange: []net.IP{net.ParseIP("2001:db8::")},
+					SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			afi, srcAddrs, err := returnConfig(tt.path, tt.hops)
+			if tt.wantErr {
+				assert.Error(t, err, tt.name)
+				return
+			}
+
+			assert.NoError(t, err, tt.name)
+			assert.Equal(t, tt.expectedAFI, afi, tt.name)
+			if tt.expectedSrcAddr == nil {
+				assert.Empty(t, srcAddrs, tt.name)
+				return
+			}
+
+			assert.True(t, tt.expectedSrcAddr.Equal(srcAddrs[0]), tt.name)
+		})
+	}
+}
+
+func TestValidateWindows(t *testing.T) {
+	tests := []struct {
+		name                string
+		measurementLengthMS uint64
+		windowsMS           []uint64
+		wantErr             string
+	}{
+		{
+			name:                "no windows",
+			measurementLengthMS: 1000,
+		},
+		{
+			name:                "multiples",
+			measurementLengthMS: 1000,
+			windowsMS:           []uint64{60000, 300000},
+		},
+		{
+			name:                "not a multiple",
+			measurementLengthMS: 1000,
+			windowsMS:           []uint64{1500},
+			wantErr:             "not a multiple",
+		},
+		{
+			name:                "measurement length",
+			measurementLengthMS: 1000,
+			windowsMS:           []uint64{1000},
+			wantErr:             "must be longer than the measurement length",
+		},
+		{
+			name:                "shorter than the measurement length",
+			measurementLengthMS: 1000,
+			windowsMS:           []uint64{500},
+			wantErr:             "must be longer than the measurement length",
+		},
+		{
+			name:                "duplicate",
+			measurementLengthMS: 1000,
+			windowsMS:           []uint64{60000, 60000},
+			wantErr:             "more than once",
+		},
+	}
+
+	for _, test := range tests {
+		err := validateWindows(test.measurementLengthMS, test.windowsMS)
+		if test.wantErr != "" {
+			assert.ErrorContains(t, err, test.wantErr, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+	}
+}
+
+func TestTargetConfigEqual(t *testing.T) {
+	newCfg := func() *TargetConfig {
+		return &TargetConfig{
+			Name: "test-target",
+			TOS:  TOS{Name: "BE"},
+			Hops: []config.Hop{
+				{
+					Name:     "r1",
+					SrcRange: []net.IP{net.ParseIP("192.0.2.0")},
+					DstRange: []net.IP{net.ParseIP("169.254.0.0")},
+				},
+			},
+			SrcAddrs: []net.IP{net.ParseIP("192.0.2.0")},
+			StaticLabels: convertLabels(map[string]string{
+				"site":   "fra01",
+				"region": "eu",
+				"tier":   "core",
+				"vendor": "acme",
+			}),
+			MeasurementLengthMS: 1000,
+			TimeoutMS:           500,
+			ReturnAFI:           4,
+			Quantiles:           []float64{0.5, 0.99},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(tc *TargetConfig)
+		expected bool
+	}{
+		{
+			name:     "unchanged",
+			modify:   func(tc *TargetConfig) {},
+			expected: true,
+		},
+		{
+			name: "hop changed",
+			modify: func(tc *TargetConfig) {
+				tc.Hops[0].DstRange = []net.IP{net.ParseIP("169.254.0.1")}
+			},
+			expected: false,
+		},
+		{
+			name: "source addresses changed",
+			modify: func(tc *TargetConfig) {
+				tc.SrcAddrs = []net.IP{net.ParseIP("192.0.2.1")}
+			},
+			expected: false,
+		},
+		{
+			name: "labels changed",
+			modify: func(tc *TargetConfig) {
+				tc.StaticLabels[1].Value = "ber01"
+			},
+			expected: false,
+		},
+		{
+			name: "timeout changed",
+			modify: func(tc *TargetConfig) {
+				tc.TimeoutMS = 400
+			},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		a, b := newCfg(), newCfg()
+		test.modify(b)
+
+		assert.Equal(t, test.expected, a.Equal(b), test.name)
+	}
+
+	// The labels of a path come from a map, so they must not depend on its iteration order
+	for range 50 {
+		assert.True(t, newCfg().Equal(newCfg()), "labels in random order")
+	}
+}
+
+func TestSequenceNumber(t *testing.T) {
+	ta := &Target{}
+
+	assert.Equal(t, uint64(0), ta.NextSequenceNumber())
+	// A probe that failed to be sent does not consume its sequence number
+	assert.Equal(t, uint64(0), ta.NextSequenceNumber())
+
+	ta.AdvanceSequenceNumber()
+	assert.Equal(t, uint64(1), ta.NextSequenceNumber())
+}
@@ -0,0 +1,60 @@
+package target
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// totals are the monotonically increasing counters of a target. They survive reconfigurations not changing the target.
+type totals struct {
+	sent     uint64
+	received uint64
+	timedOut uint64
+	late     uint64
+	rttSumNS uint64
+}
+
+// Totals is a snapshot of the counters of a target
+type Totals struct {
+	// Sent probes
+	Sent uint64
+	// Received probes that arrived within the timeout
+	Received uint64
+	// TimedOut probes that did not arrive at all
+	TimedOut uint64
+	// Late probes that arrived after the timeout
+	Late uint64
+	// RTTSum of the received probes in seconds
+	RTTSum float64
+}
+
+// ProbeSent counts a probe that was put on the wire
+func (t *Target) ProbeSent() {
+	atomic.AddUint64(&t.totals.sent, 1)
+}
+
+// ProbeReturned counts a probe that arrived within the timeout after rtt nanoseconds
+func (t *Target) ProbeReturned(rtt uint64) {
+	atomic.AddUint64(&t.totals.received, 1)
+	atomic.AddUint64(&t.totals.rttSumNS, rtt)
+}
+
+// ProbeTimedOut counts a probe that never arrived
+func (t *Target) ProbeTimedOut() {
+	atomic.AddUint64(&t.totals.timedOut, 1)
+}
+
+func (t *Target) LatePacket() {
+	atomic.AddUint64(&t.totals.late, 1)
+}
+
+// Totals returns the counters of the target
+func (t *Target) Totals() Totals {
+	return Totals{
+		Sent:     atomic.LoadUint64(&t.totals.sent),
+		Received: atomic.LoadUint64(&t.totals.received),
+		TimedOut: atomic.LoadUint64(&t.totals.timedOut),
+		Late:     atomic.LoadUint64(&t.totals.late),
+		RTTSum:   float64(atomic.LoadUint64(&t.totals.rttSumNS)) / float64(time.Second),
+	}
+}
@@ -0,0 +1,28 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetTotals(t *testing.T) {
+	ta := &Target{}
+
+	ta.ProbeSent()
+	ta.ProbeSent()
+	ta.ProbeSent()
+	ta.ProbeSent()
+	ta.ProbeReturned(1500000000)
+	ta.ProbeReturned(500000000)
+	ta.ProbeTimedOut()
+	ta.LatePacket()
+
+	assert.Equal(t, Totals{
+		Sent:     4,
+		Received: 2,
+		TimedOut: 1,
+		Late:     1,
+		RTTSum:   2,
+	}, ta.Totals())
+}
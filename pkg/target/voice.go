@@ -0,0 +1,87 @@
+package target
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+)
+
+const (
+	// dfltR0MinusIs is the basic signal-to-noise ratio minus the simultaneous impairments with the default values of ITU-T G.107
+	dfltR0MinusIs = 93.2
+)
+
+// codec holds the parameters of a voice codec for the E-model (ITU-T G.113 Appendix I)
+type codec struct {
+	// ie is the equipment impairment factor
+	ie float64
+	// bpl is the packet loss robustness factor
+	bpl float64
+	// delayMS is the delay added by packetization and look-ahead
+	delayMS float64
+}
+
+var codecs = map[string]codec{
+	config.CodecG711: {
+		ie:      0,
+		bpl:     25.1,
+		delayMS: 20,
+	},
+	config.CodecG729: {
+		ie:      11,
+		bpl:     19,
+		delayMS: 25,
+	},
+}
+
+func getCodec(name string) (codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return codec{}, fmt.Errorf("unknown codec %q", name)
+	}
+
+	return c, nil
+}
+
+// rFactor returns the R-factor of the simplified E-model (ITU-T G.107) for a one way delay and a ratio of lost packets.
+// Losses are assumed to be random.
+func (c codec) rFactor(delayMS float64, loss float64) float64 {
+	ta := delayMS + c.delayMS
+	id := 0.024 * ta
+	if ta > 177.3 {
+		id += 0.11 * (ta - 177.3)
+	}
+
+	ppl := loss * 100
+	ieEff := c.ie + (95-c.ie)*ppl/(ppl+c.bpl)
+
+	return dfltR0MinusIs - id - ieEff
+}
+
+// mos converts an R-factor to the estimated mean opinion score (ITU-T G.107 Annex B)
+func mos(r float64) float64 {
+	if r <= 0 {
+		return 1
+	}
+
+	if r >= 100 {
+		return 4.5
+	}
+
+	return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+}
+
+// VoiceQuality returns the R-factor and MOS of the codec of the target given the average RTT, the jitter and the ratio of lost packets.
+// The one way delay is half the RTT plus a jitter buffer of twice the jitter. The bool is false if the target has no codec.
+func (t *Target) VoiceQuality(rttNS float64, jitterNS float64, loss float64) (float64, float64, bool) {
+	c, ok := codecs[t.cfg.Codec]
+	if !ok {
+		return 0, 0, false
+	}
+
+	delayMS := (rttNS/2 + 2*jitterNS) / float64(time.Millisecond)
+	r := c.rFactor(delayMS, loss)
+
+	return r, mos(r), true
+}
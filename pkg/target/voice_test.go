@@ -0,0 +1,72 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/bio-routing/matroschka-prober/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetVoiceQuality(t *testing.T) {
+	tests := []struct {
+		name        string
+		codec       string
+		rttNS       float64
+		jitterNS    float64
+		loss        float64
+		expectedR   float64
+		expectedMOS float64
+		expectedOK  bool
+	}{
+		{
+			name:       "no codec",
+			expectedOK: false,
+		},
+		{
+			name:        "g711 perfect path",
+			codec:       config.CodecG711,
+			expectedR:   92.72,
+			expectedMOS: 4.3998,
+			expectedOK:  true,
+		},
+		{
+			name:        "g729 with jitter and loss",
+			codec:       config.CodecG729,
+			rttNS:       20e6,
+			jitterNS:    2e6,
+			loss:        0.01,
+			expectedR:   77.064,
+			expectedMOS: 3.9084,
+			expectedOK:  true,
+		},
+		{
+			name:        "g711 long delay",
+			codec:       config.CodecG711,
+			rttNS:       400e6,
+			expectedR:   83.223,
+			expectedMOS: 4.1398,
+			expectedOK:  true,
+		},
+		{
+			name:        "g729 blackhole",
+			codec:       config.CodecG729,
+			loss:        1,
+			expectedR:   11.0118,
+			expectedMOS: 1.0494,
+			expectedOK:  true,
+		},
+	}
+
+	for _, test := range tests {
+		ta := &Target{
+			cfg: TargetConfig{
+				Codec: test.codec,
+			},
+		}
+
+		r, mos, ok := ta.VoiceQuality(test.rttNS, test.jitterNS, test.loss)
+		assert.Equal(t, test.expectedOK, ok, test.name)
+		assert.InDelta(t, test.expectedR, r, 0.0001, test.name)
+		assert.InDelta(t, test.expectedMOS, mos, 0.0001, test.name)
+	}
+}